@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"time"
+
+	"github.com/DmytroHalai/kpi-3/painter"
+)
+
+// autosaveInterval — як часто сцена перевіряється на зміни та записується у файл -autosave.
+const autosaveInterval = 10 * time.Second
+
+// autosaver записує сцену циклу у файл, якщо вона змінилась з попереднього запису.
+type autosaver struct {
+	loop *painter.Loop
+	path string
+	last []byte
+}
+
+// run зберігає сцену кожні autosaveInterval, доки не завершиться ctx, а тоді зберігає її востаннє.
+func (a *autosaver) run(ctx context.Context) {
+	t := time.NewTicker(autosaveInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			a.save()
+			return
+		case <-t.C:
+			a.save()
+		}
+	}
+}
+
+func (a *autosaver) save() {
+	scene := a.loop.Scene()
+	var buf bytes.Buffer
+	if err := painter.EncodeScene(&buf, &scene); err != nil {
+		log.Printf("Autosave failed: %s", err)
+		return
+	}
+	if bytes.Equal(buf.Bytes(), a.last) {
+		return
+	}
+	if err := painter.SaveScene(a.path, &scene); err != nil {
+		log.Printf("Autosave failed: %s", err)
+		return
+	}
+	a.last = buf.Bytes()
+}
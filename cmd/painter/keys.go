@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"time"
+
+	"github.com/DmytroHalai/kpi-3/painter"
+	"github.com/DmytroHalai/kpi-3/ui"
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+	"golang.org/x/mobile/event/key"
+)
+
+// Крок зсуву вибраної фігури стрілками; з Shift він у 10 разів більший.
+const nudgeStep = 0.01
+
+// keyBindings повертає прив'язки клавіш вікна. Усі зміни сцени додаються у цикл подій як операції.
+func keyBindings(pv *ui.Visualizer, loop *painter.Loop, editor *painter.Editor, frames *headless.Recorder) []ui.Binding {
+	post := func(ops ...painter.Operation) func() {
+		return func() { _ = loop.Post(append(painter.OperationList(ops), painter.UpdateOp)) }
+	}
+	nudge := func(dx, dy float64) func() {
+		return func() { editor.Nudge(dx, dy) }
+	}
+	bind := func(name string, code key.Code, mod key.Modifiers, help string, action func()) ui.Binding {
+		return ui.Binding{Name: name, Shortcut: ui.Shortcut{Code: code, Modifiers: mod}, Help: help, Action: action}
+	}
+	green := color.RGBA{G: 128, A: 255}
+	return []ui.Binding{
+		bind("undo", key.CodeZ, key.ModControl, "undo", post(painter.Undo{})),
+		bind("redo", key.CodeY, key.ModControl, "redo", post(painter.Redo{})),
+		bind("redo", key.CodeZ, key.ModControl|key.ModShift, "redo", post(painter.Redo{})),
+		bind("reset", key.CodeR, 0, "clear the scene", post(painter.Record{}, painter.ResetOp())),
+		bind("background", key.CodeB, 0, "toggle white/green background", post(painter.Record{}, painter.ToggleFill{A: color.White, B: green})),
+		bind("left", key.CodeLeftArrow, 0, "move the selected figure", nudge(-nudgeStep, 0)),
+		bind("right", key.CodeRightArrow, 0, "move the selected figure", nudge(nudgeStep, 0)),
+		bind("up", key.CodeUpArrow, 0, "move the selected figure", nudge(0, -nudgeStep)),
+		bind("down", key.CodeDownArrow, 0, "move the selected figure", nudge(0, nudgeStep)),
+		bind("fast-left", key.CodeLeftArrow, key.ModShift, "move the selected figure faster", nudge(-10*nudgeStep, 0)),
+		bind("fast-right", key.CodeRightArrow, key.ModShift, "move the selected figure faster", nudge(10*nudgeStep, 0)),
+		bind("fast-up", key.CodeUpArrow, key.ModShift, "move the selected figure faster", nudge(0, -10*nudgeStep)),
+		bind("fast-down", key.CodeDownArrow, key.ModShift, "move the selected figure faster", nudge(0, 10*nudgeStep)),
+		bind("snapshot", key.CodeS, key.ModControl, "save the frame to a PNG file", func() { go saveSnapshot(frames) }),
+		bind("help", key.CodeF1, 0, "show or hide this help", pv.ToggleHelp),
+	}
+}
+
+// saveSnapshot зберігає останній кадр у поточний каталог у файл з часом у назві.
+func saveSnapshot(frames *headless.Recorder) {
+	img := frames.Frame()
+	if img == nil {
+		log.Printf("No frame to save yet")
+		return
+	}
+	name := fmt.Sprintf("snapshot-%s.png", time.Now().Format("20060102-150405.000"))
+	f, err := os.Create(name)
+	if err != nil {
+		log.Printf("Cannot save snapshot: %s", err)
+		return
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("Cannot save snapshot: %s", err)
+		return
+	}
+	log.Printf("Snapshot saved to %s", name)
+}
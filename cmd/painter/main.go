@@ -1,33 +1,132 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"image"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/DmytroHalai/kpi-3/painter"
 	"github.com/DmytroHalai/kpi-3/painter/lang"
 	"github.com/DmytroHalai/kpi-3/ui"
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+	"golang.org/x/exp/shiny/screen"
+)
+
+var (
+	noWindow        = flag.Bool("headless", false, "render frames in memory without opening a window")
+	fps             = flag.Int("fps", painter.DefaultFPS, "maximum frame rate of the window and animations")
+	canvasSize      = flag.String("size", "400x400", "canvas size in pixels; in a window it follows the window size in device pixels")
+	letterbox       = flag.Bool("letterbox", false, "keep the -size aspect ratio in the window, adding black bars")
+	keys            = flag.String("keys", "", "override key bindings, e.g. \"undo=Ctrl+U,help=H\"; F1 in the window lists them")
+	load            = flag.String("load", "", "load the scene from a file saved by the save command or -autosave")
+	autosave        = flag.String("autosave", "", "save the scene to this file periodically and on exit")
+	sceneDir        = flag.String("scene-dir", ".", "directory for files of the save and load commands")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "time to finish queued operations and HTTP requests on exit")
 )
 
 func main() {
+	flag.Parse()
+
 	var (
 		pv ui.Visualizer // Візуалізатор створює вікно та малює у ньому.
 
 		// Потрібні для частини 2.
 		opLoop painter.Loop // Цикл обробки команд.
 		parser lang.Parser  // Парсер команд.
-		scene  painter.Scene
-	)
 
-	pv.Title = "Simple painter"
+		frames headless.Recorder // Зберігає останній кадр для /snapshot.
+	)
 
-	pv.OnScreenReady = opLoop.Start
-	opLoop.Receiver = &pv
+	parser.SceneDir = *sceneDir
+	if *load != "" {
+		scene, err := painter.LoadScene(*load)
+		if err != nil {
+			log.Fatalf("Cannot load scene: %s", err)
+		}
+		// Черга приймає операції ще до запуску циклу.
+		_ = opLoop.Post(painter.OperationList{painter.ReplaceScene{Scene: scene}, painter.UpdateOp})
+	}
+	saveCtx, stopSaving := context.WithCancel(context.Background())
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		if *autosave != "" {
+			(&autosaver{loop: &opLoop, path: *autosave}).run(saveCtx)
+		}
+	}()
 
+	mux := http.NewServeMux()
+	mux.Handle("/", lang.HttpHandler(&opLoop, &parser))
+	mux.Handle("/ops", lang.JSONHandler(&opLoop, &parser))
+	mux.Handle("/ops/schema", lang.SchemaHandler())
+	mux.Handle("/snapshot", lang.SnapshotHandler(&frames))
+	mux.Handle("/assets", lang.AssetHandler(&opLoop))
+	mux.Handle("/debug/vars", expvar.Handler())
+	expvar.Publish("frames", expvar.Func(func() any { return opLoop.Stats() }))
+	srv := &http.Server{Addr: "localhost:17000", Handler: mux}
 	go func() {
-		http.Handle("/", lang.HttpHandler(&opLoop, &parser, &scene))
-		_ = http.ListenAndServe("localhost:17000", nil)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server failed: %s", err)
+		}
 	}()
 
-	pv.Main()
-	opLoop.StopAndWait()
+	opLoop.Receiver = &frames
+	opLoop.FPS = *fps
+	var w, h int
+	if _, err := fmt.Sscanf(*canvasSize, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		log.Fatalf("Invalid canvas size %q, expected WIDTHxHEIGHT", *canvasSize)
+	}
+	opLoop.Size = image.Pt(w, h)
+	if *noWindow {
+		opLoop.Start(headless.Screen{})
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		<-ctx.Done()
+		stop()
+	} else {
+		pv.Title = "Simple painter"
+
+		// Кадри формуються на екрані вікна, а Recorder зберігає їхні копії для /snapshot.
+		pv.OnScreenReady = func(s screen.Screen) { opLoop.Start(frames.Wrap(s)) }
+		pv.KeepAspect = *letterbox
+		pv.Aspect = opLoop.Size
+		pv.OnResize = func(size image.Point) {
+			if err := opLoop.Resize(size); err != nil {
+				log.Printf("Cannot resize canvas: %s", err)
+			}
+		}
+		frames.Next = &pv
+		// Дії миші змінюють ту саму сцену, що й HTTP-клієнти.
+		editor := painter.Editor{Loop: &opLoop}
+		pv.OnPointer = editor.Pointer
+		bindings, err := ui.Rebind(keyBindings(&pv, &opLoop, &editor, &frames), *keys)
+		if err != nil {
+			log.Fatalf("Invalid -keys: %s", err)
+		}
+		pv.Bindings = bindings
+
+		pv.Main()
+	}
+
+	// Спочатку перестаємо приймати запити, потім виконуємо те, що вже встигли додати у чергу.
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown: %s", err)
+	}
+	if err := opLoop.Shutdown(ctx); err != nil {
+		log.Printf("Loop shutdown: %s", err)
+	}
+	// Після зупинки циклу сцена вже не зміниться, тож її можна зберегти востаннє.
+	stopSaving()
+	<-saved
 }
@@ -0,0 +1,156 @@
+package painter
+
+import (
+	"math"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// CubicBezier — функція плавності у форматі CSS cubic-bezier(X1, Y1, X2, Y2). Нульове значення еквівалентне Linear.
+type CubicBezier struct {
+	X1, Y1, X2, Y2 float64
+}
+
+// Стандартні функції плавності CSS.
+var (
+	Linear    = CubicBezier{0, 0, 1, 1}
+	Ease      = CubicBezier{0.25, 0.1, 0.25, 1}
+	EaseIn    = CubicBezier{0.42, 0, 1, 1}
+	EaseOut   = CubicBezier{0, 0, 0.58, 1}
+	EaseInOut = CubicBezier{0.42, 0, 0.58, 1}
+)
+
+// Ease повертає частку пройденого шляху для частки часу t з [0, 1].
+func (c CubicBezier) Ease(t float64) float64 {
+	if t <= 0 {
+		return 0
+	}
+	if t >= 1 {
+		return 1
+	}
+	// Знаходимо параметр кривої u, для якого x(u) = t: спершу методом Ньютона, а якщо він не збігся — бісекцією.
+	u := t
+	for range 8 {
+		dx := bezierSlope(u, c.X1, c.X2)
+		if math.Abs(dx) < 1e-6 {
+			break
+		}
+		x := bezier(u, c.X1, c.X2) - t
+		if math.Abs(x) < 1e-7 {
+			return bezier(u, c.Y1, c.Y2)
+		}
+		u -= x / dx
+	}
+	lo, hi := 0.0, 1.0
+	u = t
+	for range 50 {
+		x := bezier(u, c.X1, c.X2)
+		if math.Abs(x-t) < 1e-7 {
+			break
+		}
+		if x < t {
+			lo = u
+		} else {
+			hi = u
+		}
+		u = (lo + hi) / 2
+	}
+	return bezier(u, c.Y1, c.Y2)
+}
+
+// bezier обчислює координату кривої з кінцями 0 та 1 та контрольними точками p1, p2.
+func bezier(u, p1, p2 float64) float64 {
+	v := 1 - u
+	return 3*v*v*u*p1 + 3*v*u*u*p2 + u*u*u
+}
+
+func bezierSlope(u, p1, p2 float64) float64 {
+	v := 1 - u
+	return 3*v*v*p1 + 6*v*u*(p2-p1) + 3*u*u*(1-p2)
+}
+
+// Tween — переміщення фігури, яке виконується протягом Duration. Позицію фігури оновлюють операції Tick.
+type Tween struct {
+	ID           string
+	FromX, FromY float64
+	ToX, ToY     float64
+	Duration     time.Duration
+	Elapsed      time.Duration
+	Easing       CubicBezier
+}
+
+// Animate запускає переміщення фігури з указаним ID з поточної позиції у точку (X, Y) за Duration. Без ID
+// анімуються всі фігури. Нова анімація фігури замінює попередню.
+type Animate struct {
+	ID       string
+	X        float64
+	Y        float64
+	Duration time.Duration
+	Easing   CubicBezier
+}
+
+func (op Animate) Do(t screen.Texture, s *Scene) bool {
+	for i := range s.Shapes {
+		shape := &s.Shapes[i]
+		if op.ID != "" && shape.ID != op.ID {
+			continue
+		}
+		s.stopTween(shape.ID)
+		if op.Duration <= 0 {
+			shape.X, shape.Y = op.X, op.Y
+			continue
+		}
+		s.Tweens = append(s.Tweens, Tween{
+			ID:       shape.ID,
+			FromX:    shape.X,
+			FromY:    shape.Y,
+			ToX:      op.X,
+			ToY:      op.Y,
+			Duration: op.Duration,
+			Easing:   op.Easing,
+		})
+	}
+	render(s, t)
+	return false
+}
+
+// Tick просуває всі анімації сцени на DT та формує кадр, якщо хоч одна анімація виконувалась. Завершені анімації та
+// анімації видалених фігур прибираються.
+type Tick struct {
+	DT time.Duration
+}
+
+func (op Tick) Do(t screen.Texture, s *Scene) bool {
+	if len(s.Tweens) == 0 {
+		return false
+	}
+	active := s.Tweens[:0]
+	for _, tw := range s.Tweens {
+		i := s.Shape(tw.ID)
+		if i < 0 {
+			continue
+		}
+		tw.Elapsed = min(tw.Elapsed+op.DT, tw.Duration)
+		k := tw.Easing.Ease(float64(tw.Elapsed) / float64(tw.Duration))
+		s.Shapes[i].X = tw.FromX + (tw.ToX-tw.FromX)*k
+		s.Shapes[i].Y = tw.FromY + (tw.ToY-tw.FromY)*k
+		if tw.Elapsed < tw.Duration {
+			active = append(active, tw)
+		}
+	}
+	clear(s.Tweens[len(active):])
+	s.Tweens = active
+	render(s, t)
+	return true
+}
+
+// stopTween скасовує анімацію фігури з указаним ID.
+func (s *Scene) stopTween(id string) {
+	for i, tw := range s.Tweens {
+		if tw.ID == id {
+			s.Tweens = append(s.Tweens[:i], s.Tweens[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package painter
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+func TestCubicBezier_Ease(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		easing  CubicBezier
+		t, want float64
+	}{
+		{"linear", Linear, 0.3, 0.3},
+		{"zero value", CubicBezier{}, 0.7, 0.7},
+		{"in-out middle", EaseInOut, 0.5, 0.5},
+		{"ease-in start", EaseIn, 0.25, 0.0935},
+		{"ease-out start", EaseOut, 0.25, 0.3781},
+		{"before start", Ease, -1, 0},
+		{"after end", Ease, 2, 1},
+	} {
+		if got := tc.easing.Ease(tc.t); math.Abs(got-tc.want) > 1e-3 {
+			t.Errorf("%s: Ease(%g) = %g, want %g", tc.name, tc.t, got, tc.want)
+		}
+	}
+}
+
+func TestAnimate_Tick(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddShape{ID: "a", X: 0, Y: 0.25},
+		AddShape{ID: "b", X: 0.5, Y: 0.5},
+		Animate{ID: "a", X: 0.5, Y: 0.75, Duration: time.Second},
+	)
+	if len(s.Tweens) != 1 {
+		t.Fatalf("expected 1 tween, got %+v", s.Tweens)
+	}
+
+	tex := new(mockTexture)
+	if !(Tick{DT: 250 * time.Millisecond}).Do(tex, &s) {
+		t.Error("expected a frame while the animation runs")
+	}
+	if a := s.Shapes[s.Shape("a")]; math.Abs(a.X-0.125) > 1e-9 || math.Abs(a.Y-0.375) > 1e-9 {
+		t.Errorf("unexpected position after 1/4 of the animation: %+v", a)
+	}
+
+	Tick{DT: time.Second}.Do(tex, &s)
+	if a := s.Shapes[s.Shape("a")]; a.X != 0.5 || a.Y != 0.75 || len(s.Tweens) != 0 {
+		t.Errorf("animation did not finish: %+v, %+v", a, s.Tweens)
+	}
+	if (Tick{DT: time.Second}).Do(tex, &s) {
+		t.Error("expected no frame without animations")
+	}
+
+	applyOps(&s, Animate{X: 0, Y: 0, Duration: time.Second}, MoveShapes{ID: "b", X: 1, Y: 1})
+	if len(s.Tweens) != 1 || s.Tweens[0].ID != "a" {
+		t.Errorf("move must cancel the animation of b: %+v", s.Tweens)
+	}
+}
+
+type countingReceiver struct{ frames atomic.Int32 }
+
+func (r *countingReceiver) Update(screen.Texture) { r.frames.Add(1) }
+
+func TestLoop_Animation(t *testing.T) {
+	var r countingReceiver
+	l := Loop{Receiver: &r, FPS: 200}
+	l.Start(mockScreen{})
+
+	l.Post(AddShape{ID: "a"})
+	l.Post(Animate{ID: "a", X: 0.25, Y: 0.25, Duration: 50 * time.Millisecond, Easing: EaseInOut})
+
+	deadline := time.Now().Add(time.Second)
+	for s := l.Scene(); len(s.Tweens) > 0 || s.Shape("a") < 0; s = l.Scene() {
+		if time.Now().After(deadline) {
+			t.Fatal("animation did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	l.StopAndWait()
+
+	if a := l.Scene().Shapes[0]; a.X != 0.25 || a.Y != 0.25 {
+		t.Errorf("unexpected final position: %+v", a)
+	}
+	if n := r.frames.Load(); n < 2 {
+		t.Errorf("expected several animation frames, got %d", n)
+	}
+}
@@ -0,0 +1,185 @@
+package painter
+
+import (
+	"math"
+
+	"github.com/DmytroHalai/kpi-3/ui"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+// DragThreshold — відстань у нормалізованих координатах, після якої натискання вважається перетягуванням, а не
+// клацанням.
+const DragThreshold = 0.01
+
+// RectModifier — клавіша-модифікатор, з якою перетягування лівою кнопкою малює прямокутник на фоні.
+const RectModifier = key.ModShift
+
+// Editor перетворює події миші на операції циклу Loop, тож вікно та HTTP-клієнти змінюють одну сцену:
+//
+//   - клацання лівою кнопкою на порожньому місці додає фігуру;
+//   - перетягування фігури лівою кнопкою переносить її;
+//   - перетягування з RectModifier малює прямокутник;
+//   - клацання правою кнопкою видаляє фігуру під курсором;
+//   - Nudge зсуває вибрану фігуру, тобто останню додану або перенесену мишею.
+//
+// Кожен жест — окремий крок історії сцени, див. Record.
+// Пошук фігури під курсором виконується всередині операцій, тобто на актуальній сцені, а не на її копії. Стан
+// жесту також змінюється лише операціями, тому Editor не потребує синхронізації.
+type Editor struct {
+	Loop *Loop
+
+	gesture  gesture
+	id       string  // фігура, яку переносять, або прямокутник, який малюють
+	x0, y0   float64 // точка натискання
+	dx, dy   float64 // зсув центру фігури відносно курсору
+	selected string  // фігура, яку зсуває Nudge
+}
+
+type gesture int
+
+const (
+	gestureNone gesture = iota
+	gestureClick
+	gestureDrag
+	gestureRect
+)
+
+// Pointer обробляє подію миші. Його можна використати як ui.Visualizer.OnPointer.
+func (e *Editor) Pointer(ev ui.PointerEvent) {
+	var op Operation
+	switch {
+	case ev.Direction == mouse.DirPress && ev.Button == mouse.ButtonLeft:
+		op = press{e, ev.X, ev.Y, ev.Modifiers&RectModifier != 0}
+	case ev.Direction == mouse.DirPress && ev.Button == mouse.ButtonRight:
+		op = deleteAt{ev.X, ev.Y}
+	case ev.Direction == mouse.DirNone:
+		op = drag{e, ev.X, ev.Y}
+	case ev.Direction == mouse.DirRelease && ev.Button == mouse.ButtonLeft:
+		op = release{e, ev.X, ev.Y}
+	default:
+		return
+	}
+	// Після зупинки циклу редагувати вже нічого.
+	_ = e.Loop.Post(op)
+}
+
+type press struct {
+	e    *Editor
+	x, y float64
+	rect bool
+}
+
+func (op press) Do(t screen.Texture, s *Scene) bool {
+	e := op.e
+	Record{}.Do(t, s)
+	e.x0, e.y0 = op.x, op.y
+	e.id = ""
+	switch i := s.ShapeAt(op.x, op.y, t.Bounds()); {
+	case op.rect:
+		e.gesture = gestureRect
+	case i >= 0:
+		e.gesture = gestureDrag
+		e.id = s.Shapes[i].ID
+		e.selected = e.id
+		// Фігура в групі переноситься в координатах групи.
+		x, y := s.LocalPoint(s.Shapes[i].Parent, op.x, op.y, t.Bounds())
+		e.dx, e.dy = s.Shapes[i].X-x, s.Shapes[i].Y-y
+	default:
+		e.gesture = gestureClick
+	}
+	return false
+}
+
+type drag struct {
+	e    *Editor
+	x, y float64
+}
+
+func (op drag) Do(t screen.Texture, s *Scene) bool {
+	e := op.e
+	switch e.gesture {
+	case gestureClick:
+		if math.Hypot(op.x-e.x0, op.y-e.y0) > DragThreshold {
+			e.gesture = gestureNone
+		}
+		return false
+	case gestureDrag:
+		i := s.Shape(e.id)
+		if i < 0 {
+			// Фігуру видалили під час перетягування.
+			e.gesture = gestureNone
+			return false
+		}
+		x, y := s.LocalPoint(s.Shapes[i].Parent, op.x, op.y, t.Bounds())
+		MoveShapes{ID: e.id, X: x + e.dx, Y: y + e.dy}.Do(t, s)
+		return true
+	case gestureRect:
+		if e.id == "" {
+			if math.Hypot(op.x-e.x0, op.y-e.y0) <= DragThreshold {
+				return false
+			}
+			e.id = s.newID("r")
+		}
+		AddRect{
+			ID: e.id,
+			X1: min(e.x0, op.x), Y1: min(e.y0, op.y),
+			X2: max(e.x0, op.x), Y2: max(e.y0, op.y),
+		}.Do(t, s)
+		return true
+	}
+	return false
+}
+
+type release struct {
+	e    *Editor
+	x, y float64
+}
+
+func (op release) Do(t screen.Texture, s *Scene) bool {
+	e := op.e
+	ready := drag(op).Do(t, s)
+	if e.gesture == gestureClick {
+		e.selected = s.newID("f")
+		AddShape{ID: e.selected, X: e.x0, Y: e.y0}.Do(t, s)
+		ready = true
+	}
+	e.gesture = gestureNone
+	return ready
+}
+
+// deleteAt видаляє найвищу фігуру під точкою (x, y).
+type deleteAt struct {
+	x, y float64
+}
+
+func (op deleteAt) Do(t screen.Texture, s *Scene) bool {
+	i := s.ShapeAt(op.x, op.y, t.Bounds())
+	if i < 0 {
+		return false
+	}
+	Record{}.Do(t, s)
+	Delete{ID: s.Shapes[i].ID}.Do(t, s)
+	return true
+}
+
+// Nudge зсуває вибрану фігуру на (dx, dy).
+func (e *Editor) Nudge(dx, dy float64) {
+	_ = e.Loop.Post(nudge{e, dx, dy})
+}
+
+type nudge struct {
+	e      *Editor
+	dx, dy float64
+}
+
+func (op nudge) Do(t screen.Texture, s *Scene) bool {
+	if s.Shape(op.e.selected) < 0 {
+		return false
+	}
+	Record{}.Do(t, s)
+	MoveShapes{ID: op.e.selected, X: op.dx, Y: op.dy, Relative: true}.Do(t, s)
+	return true
+}
@@ -0,0 +1,105 @@
+package painter
+
+import (
+	"image"
+	"testing"
+
+	"github.com/DmytroHalai/kpi-3/ui"
+
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestScene_ShapeAt(t *testing.T) {
+	var s Scene
+	applyOps(&s, AddShape{ID: "a", X: 0.5, Y: 0.5}, AddShape{ID: "b", X: 0.55, Y: 0.5}, Lower{ID: "b"})
+	b := image.Rect(0, 0, 400, 400)
+	for _, tc := range []struct {
+		x, y float64
+		want string
+	}{
+		{0.5, 0.5, "a"},   // обидві фігури, a вище
+		{0.56, 0.6, "b"},  // лише ніжка b
+		{0.35, 0.34, "a"}, // перекладина a
+		{0.35, 0.6, ""},   // поруч з ніжкою
+		{0.1, 0.1, ""},    // порожнє місце
+	} {
+		got := ""
+		if i := s.ShapeAt(tc.x, tc.y, b); i >= 0 {
+			got = s.Shapes[i].ID
+		}
+		if got != tc.want {
+			t.Errorf("ShapeAt(%g, %g) = %q, want %q", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestEditor_Pointer(t *testing.T) {
+	var l Loop
+	l.Receiver = new(testReceiver)
+	l.Start(mockScreen{})
+	l.Post(AddShape{ID: "a", X: 0.5, Y: 0.5})
+	e := Editor{Loop: &l}
+	ev := func(x, y float64, b mouse.Button, d mouse.Direction, m key.Modifiers) {
+		e.Pointer(ui.PointerEvent{X: x, Y: y, Button: b, Direction: d, Modifiers: m})
+	}
+
+	// Клацання на порожньому місці додає фігуру.
+	ev(0.2, 0.2, mouse.ButtonLeft, mouse.DirPress, 0)
+	ev(0.2, 0.2, mouse.ButtonLeft, mouse.DirRelease, 0)
+	// Перетягування фігури зберігає зсув курсору відносно її центру.
+	ev(0.51, 0.6, mouse.ButtonLeft, mouse.DirPress, 0)
+	ev(0.6, 0.7, mouse.ButtonNone, mouse.DirNone, 0)
+	ev(0.71, 0.8, mouse.ButtonLeft, mouse.DirRelease, 0)
+	// Перетягування порожнього місця без модифікатора нічого не додає.
+	ev(0.9, 0.1, mouse.ButtonLeft, mouse.DirPress, 0)
+	ev(0.95, 0.2, mouse.ButtonLeft, mouse.DirRelease, 0)
+	// Перетягування з модифікатором малює прямокутник.
+	ev(0.9, 0.9, mouse.ButtonLeft, mouse.DirPress, RectModifier)
+	ev(0.8, 0.85, mouse.ButtonNone, mouse.DirNone, 0)
+	ev(0.7, 0.8, mouse.ButtonLeft, mouse.DirRelease, 0)
+	// Клацання правою кнопкою видаляє фігуру під курсором.
+	ev(0.2, 0.2, mouse.ButtonRight, mouse.DirPress, 0)
+	ev(0.1, 0.5, mouse.ButtonRight, mouse.DirPress, 0)
+	// Рух без натиснутої кнопки ігнорується.
+	ev(0.3, 0.3, mouse.ButtonNone, mouse.DirNone, 0)
+	l.StopAndWait()
+
+	s := l.Scene()
+	if len(s.Shapes) != 1 {
+		t.Fatalf("expected 1 shape, got %+v", s.Shapes)
+	}
+	if a := s.Shapes[0]; a.ID != "a" || !near(a.X, 0.7) || !near(a.Y, 0.7) {
+		t.Errorf("shape was not dragged: %+v", a)
+	}
+	if len(s.Rects) != 1 {
+		t.Fatalf("expected 1 rect, got %+v", s.Rects)
+	}
+	if r := s.Rects[0]; !near(r.X1, 0.7) || !near(r.Y1, 0.8) || !near(r.X2, 0.9) || !near(r.Y2, 0.9) {
+		t.Errorf("unexpected rect: %+v", r)
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
+
+func TestEditor_Nudge(t *testing.T) {
+	var l Loop
+	l.Receiver = new(testReceiver)
+	l.Start(mockScreen{})
+	e := Editor{Loop: &l}
+
+	e.Nudge(0.1, 0) // Фігуру ще не вибрано.
+	e.Pointer(ui.PointerEvent{X: 0.5, Y: 0.5, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	e.Pointer(ui.PointerEvent{X: 0.5, Y: 0.5, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	e.Nudge(0.1, 0)
+	e.Nudge(0, -0.2)
+	l.Post(Undo{})
+	l.StopAndWait()
+
+	s := l.Scene()
+	if len(s.Shapes) != 1 || !near(s.Shapes[0].X, 0.6) || !near(s.Shapes[0].Y, 0.5) {
+		t.Errorf("unexpected shapes: %+v", s.Shapes)
+	}
+}
@@ -0,0 +1,437 @@
+package painter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// SceneVersion — версія формату файлу сцени, який записує EncodeScene. Файли попередніх версій перетворюються під
+// час читання функцією migrateScene.
+const SceneVersion = 7
+
+// sceneFile — файл сцени у форматі JSON. Анімації не зберігаються: фігури записуються в поточних позиціях.
+// Зображення записуються лише назвами ресурсів, самі растри до файлу не потрапляють.
+type sceneFile struct {
+	Version    int         `json:"version"`
+	Background *fileColor  `json:"background,omitempty"`
+	BgPaint    *filePaint  `json:"background_paint,omitempty"` // з версії 6
+	Rects      []fileRect  `json:"rects,omitempty"`
+	Shapes     []fileShape `json:"shapes,omitempty"`
+	Groups     []fileGroup `json:"groups,omitempty"`     // з версії 2
+	Primitives []filePrim  `json:"primitives,omitempty"` // з версії 3
+	Texts      []fileText  `json:"texts,omitempty"`      // з версії 4
+	Images     []fileImage `json:"images,omitempty"`     // з версії 5
+}
+
+type fileRect struct {
+	ID      string     `json:"id"`
+	X1      float64    `json:"x1"`
+	Y1      float64    `json:"y1"`
+	X2      float64    `json:"x2"`
+	Y2      float64    `json:"y2"`
+	Color   *fileColor `json:"color,omitempty"`
+	Outline bool       `json:"outline,omitempty"`
+	Z       int        `json:"z"`
+	Parent  string     `json:"parent,omitempty"`
+	Paint   *filePaint `json:"paint,omitempty"`
+}
+
+type fileShape struct {
+	ID     string     `json:"id"`
+	X      float64    `json:"x"`
+	Y      float64    `json:"y"`
+	Color  *fileColor `json:"color,omitempty"`
+	Z      int        `json:"z"`
+	Parent string     `json:"parent,omitempty"`
+}
+
+type filePrim struct {
+	ID     string     `json:"id"`
+	Kind   string     `json:"kind"`
+	Params []float64  `json:"params"`
+	X      float64    `json:"x,omitempty"` // з версії 7
+	Y      float64    `json:"y,omitempty"` // з версії 7
+	Fill   *fileColor `json:"fill,omitempty"`
+	Stroke *fileColor `json:"stroke,omitempty"`
+	Width  float64    `json:"width,omitempty"`
+	Z      int        `json:"z"`
+	Parent string     `json:"parent,omitempty"`
+}
+
+type fileText struct {
+	ID      string     `json:"id"`
+	X       float64    `json:"x"`
+	Y       float64    `json:"y"`
+	Size    float64    `json:"size"`
+	Text    string     `json:"text"`
+	Color   *fileColor `json:"color,omitempty"`
+	Align   string     `json:"align,omitempty"`
+	Font    string     `json:"font,omitempty"`
+	Spacing float64    `json:"spacing,omitempty"`
+	Z       int        `json:"z"`
+	Parent  string     `json:"parent,omitempty"`
+}
+
+type fileImage struct {
+	ID     string  `json:"id"`
+	Asset  string  `json:"asset"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	W      float64 `json:"w,omitempty"`
+	H      float64 `json:"h,omitempty"`
+	Alpha  float64 `json:"alpha,omitempty"`
+	Z      int     `json:"z"`
+	Parent string  `json:"parent,omitempty"`
+}
+
+type filePaint struct {
+	Kind   string      `json:"kind"`
+	Params []float64   `json:"params"`
+	Colors []fileColor `json:"colors"`
+}
+
+func newFilePaint(p *Paint) *filePaint {
+	if p == nil {
+		return nil
+	}
+	f := &filePaint{Kind: p.Kind, Params: p.Params}
+	for _, c := range p.Colors {
+		f.Colors = append(f.Colors, *newFileColor(c))
+	}
+	return f
+}
+
+// paint повертає заливку файлу, перевіривши її.
+func (f *filePaint) paint() (*Paint, error) {
+	if f == nil {
+		return nil, nil
+	}
+	p := &Paint{Kind: f.Kind, Params: f.Params}
+	for _, c := range f.Colors {
+		p.Colors = append(p.Colors, c.c)
+	}
+	return p, p.Validate()
+}
+
+type fileGroup struct {
+	ID        string        `json:"id"`
+	Parent    string        `json:"parent,omitempty"`
+	Transform fileTransform `json:"transform"`
+	Z         int           `json:"z"`
+}
+
+type fileTransform struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Angle  float64 `json:"angle"`
+	ScaleX float64 `json:"scale_x"`
+	ScaleY float64 `json:"scale_y"`
+	PivotX float64 `json:"pivot_x"`
+	PivotY float64 `json:"pivot_y"`
+}
+
+// fileColor записується у форматі #rrggbbaa без попереднього множення на прозорість.
+type fileColor struct{ c color.NRGBA }
+
+func newFileColor(c color.Color) *fileColor {
+	if c == nil {
+		return nil
+	}
+	return &fileColor{color.NRGBAModel.Convert(c).(color.NRGBA)}
+}
+
+func (c *fileColor) color() color.Color {
+	if c == nil {
+		return nil
+	}
+	return c.c
+}
+
+func (c fileColor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("#%02x%02x%02x%02x", c.c.R, c.c.G, c.c.B, c.c.A))
+}
+
+func (c *fileColor) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("color must be a string")
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || len(b) != 4 || !strings.HasPrefix(s, "#") {
+		return fmt.Errorf("invalid color %q, expected #rrggbbaa", s)
+	}
+	c.c = color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}
+	return nil
+}
+
+// EncodeScene записує сцену у форматі JSON версії SceneVersion.
+func EncodeScene(w io.Writer, s *Scene) error {
+	f := sceneFile{Version: SceneVersion, Background: newFileColor(s.BgColor), BgPaint: newFilePaint(s.BgPaint)}
+	for _, r := range s.Rects {
+		f.Rects = append(f.Rects, fileRect{
+			ID: r.ID, X1: r.X1, Y1: r.Y1, X2: r.X2, Y2: r.Y2, Color: newFileColor(r.Color), Outline: r.Outline, Z: r.Z,
+			Parent: r.Parent, Paint: newFilePaint(r.Paint),
+		})
+	}
+	for _, sh := range s.Shapes {
+		f.Shapes = append(f.Shapes, fileShape{
+			ID: sh.ID, X: sh.X, Y: sh.Y, Color: newFileColor(sh.Color), Z: sh.Z, Parent: sh.Parent,
+		})
+	}
+	for _, p := range s.Primitives {
+		f.Primitives = append(f.Primitives, filePrim{
+			ID: p.ID, Kind: p.Kind, Params: p.Params, X: p.X, Y: p.Y, Fill: newFileColor(p.Fill),
+			Stroke: newFileColor(p.Stroke), Width: p.Width, Z: p.Z, Parent: p.Parent,
+		})
+	}
+	for _, tx := range s.Texts {
+		f.Texts = append(f.Texts, fileText{
+			ID: tx.ID, X: tx.X, Y: tx.Y, Size: tx.Size, Text: tx.Text, Color: newFileColor(tx.Color), Align: tx.Align.String(),
+			Font: tx.Font, Spacing: tx.Spacing, Z: tx.Z, Parent: tx.Parent,
+		})
+	}
+	for _, im := range s.Images {
+		f.Images = append(f.Images, fileImage(im))
+	}
+	for _, g := range s.Groups {
+		f.Groups = append(f.Groups, fileGroup{ID: g.ID, Parent: g.Parent, Transform: fileTransform(g.Transform), Z: g.Z})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// DecodeScene читає сцену, записану EncodeScene цієї або попередньої версії.
+func DecodeScene(r io.Reader) (Scene, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return Scene{}, fmt.Errorf("painter: invalid scene file: %w", err)
+	}
+	var version int
+	if err := json.Unmarshal(raw["version"], &version); err != nil || version < 1 {
+		return Scene{}, errors.New("painter: scene file has no valid version")
+	}
+	if version > SceneVersion {
+		return Scene{}, fmt.Errorf("painter: scene file version %d is newer than supported version %d", version, SceneVersion)
+	}
+	if err := migrateScene(raw, version); err != nil {
+		return Scene{}, fmt.Errorf("painter: cannot migrate scene file from version %d: %w", version, err)
+	}
+	data, _ := json.Marshal(raw)
+
+	var f sceneFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Scene{}, fmt.Errorf("painter: invalid scene file: %w", err)
+	}
+	s := Scene{BgColor: f.Background.color()}
+	var err error
+	if s.BgPaint, err = f.BgPaint.paint(); err != nil {
+		return Scene{}, fmt.Errorf("painter: invalid scene file: background paint: %w", err)
+	}
+	var ids []string
+	for _, g := range f.Groups {
+		ids = append(ids, g.ID)
+		s.Groups = append(s.Groups, Group{ID: g.ID, Parent: g.Parent, Transform: Transform(g.Transform), Z: g.Z})
+	}
+	for _, r := range f.Rects {
+		ids = append(ids, r.ID)
+		paint, err := r.Paint.paint()
+		if err != nil {
+			return Scene{}, fmt.Errorf("painter: invalid scene file: rect %q paint: %w", r.ID, err)
+		}
+		s.Rects = append(s.Rects, Rectangle{
+			ID: r.ID, X1: r.X1, Y1: r.Y1, X2: r.X2, Y2: r.Y2, Color: r.Color.color(), Outline: r.Outline, Z: r.Z,
+			Parent: r.Parent, Paint: paint,
+		})
+	}
+	for _, sh := range f.Shapes {
+		ids = append(ids, sh.ID)
+		s.Shapes = append(s.Shapes, Shape{ID: sh.ID, X: sh.X, Y: sh.Y, Color: sh.Color.color(), Z: sh.Z, Parent: sh.Parent})
+	}
+	for _, p := range f.Primitives {
+		ids = append(ids, p.ID)
+		prim := Primitive{
+			ID: p.ID, Kind: p.Kind, Params: p.Params, X: p.X, Y: p.Y, Fill: p.Fill.color(), Stroke: p.Stroke.color(),
+			Width: p.Width, Z: p.Z, Parent: p.Parent,
+		}
+		if err := prim.Validate(); err != nil {
+			return Scene{}, fmt.Errorf("painter: invalid scene file: primitive %q: %w", p.ID, err)
+		}
+		s.Primitives = append(s.Primitives, prim)
+	}
+	for _, tx := range f.Texts {
+		ids = append(ids, tx.ID)
+		text := Text{
+			ID: tx.ID, X: tx.X, Y: tx.Y, Size: tx.Size, Text: tx.Text, Color: tx.Color.color(), Font: tx.Font,
+			Spacing: tx.Spacing, Z: tx.Z, Parent: tx.Parent,
+		}
+		err := text.Validate()
+		if err == nil && tx.Align != "" {
+			text.Align, err = ParseAlign(tx.Align)
+		}
+		if err != nil {
+			return Scene{}, fmt.Errorf("painter: invalid scene file: text %q: %w", tx.ID, err)
+		}
+		s.Texts = append(s.Texts, text)
+	}
+	for _, im := range f.Images {
+		ids = append(ids, im.ID)
+		img := Image(im)
+		if err := img.Validate(); err != nil {
+			return Scene{}, fmt.Errorf("painter: invalid scene file: image %q: %w", im.ID, err)
+		}
+		s.Images = append(s.Images, img)
+	}
+
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			return Scene{}, fmt.Errorf("painter: invalid scene file: missing or duplicate id %q", id)
+		}
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if p := *s.parent(id); p != "" && s.validParent(id, p) != p {
+			return Scene{}, fmt.Errorf("painter: invalid scene file: element %q has invalid parent %q", id, p)
+		}
+	}
+	return s, nil
+}
+
+// migrateScene по черзі перетворює файл версії version до поточної. Кожна нова версія формату додає сюди крок
+// перетворення з попередньої.
+func migrateScene(raw map[string]json.RawMessage, version int) error {
+	for ; version < SceneVersion; version++ {
+		switch version {
+		case 1:
+			// Версія 2 додала групи; у файлах версії 1 усі елементи лежать у корені сцени, тож змінювати нічого.
+		case 2:
+			// Версія 3 додала примітиви, яких у файлах версії 2 немає.
+		case 3:
+			// Версія 4 додала написи, яких у файлах версії 3 немає.
+		case 4:
+			// Версія 5 додала зображення, яких у файлах версії 4 немає.
+		case 5:
+			// Версія 6 додала заливку градієнтами та візерунками; у файлах версії 5 фон і прямокутники суцільні.
+		case 6:
+			// Версія 7 додала зсув примітивів; у файлах версії 6 він нульовий.
+		default:
+			return fmt.Errorf("no migration from version %d", version)
+		}
+	}
+	raw["version"] = json.RawMessage(fmt.Sprint(SceneVersion))
+	return nil
+}
+
+// SaveScene записує сцену у файл. Файл спершу записується поруч під тимчасовою назвою, тож при збої попередній
+// вміст не втрачається.
+func SaveScene(path string, s *Scene) error {
+	var buf bytes.Buffer
+	if err := EncodeScene(&buf, s); err != nil {
+		return err
+	}
+	return writeSceneFile(path, buf.Bytes())
+}
+
+// writeSceneFile записує закодовану сцену data у файл через тимчасовий файл поруч.
+func writeSceneFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadScene читає сцену з файлу.
+func LoadScene(path string) (Scene, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Scene{}, err
+	}
+	defer f.Close()
+	return DecodeScene(f)
+}
+
+// ReplaceScene замінює вміст сцени на Scene. Історія змін зберігається, тож заміну можна скасувати. Щоб не читати
+// диск у циклі подій, файл сцени читається заздалегідь через LoadScene.
+type ReplaceScene struct {
+	Scene Scene
+}
+
+func (op ReplaceScene) Do(t screen.Texture, s *Scene) bool {
+	c := op.Scene.Clone()
+	c.history = s.history
+	*s = c
+	render(s, t)
+	return false
+}
+
+// Файли операції Save записуються поза циклом подій, але по черзі: кожен запис чекає на завершення попереднього,
+// тож файл отримує сцену з останньої операції.
+var (
+	savesMu  sync.Mutex
+	lastSave <-chan struct{} // закривається, коли завершено останній розпочатий запис
+)
+
+// Save записує сцену у файл Path, див. SaveScene. У циклі подій сцена лише кодується, а файл записується окремою
+// горутиною, щоб диск не затримував кадри та інші операції. Результат запису надсилається в Done, а якщо його не
+// задано, помилка журналюється. Done має бути буферизованим.
+type Save struct {
+	Path string
+	Done chan<- error
+}
+
+func (op Save) Do(t screen.Texture, s *Scene) bool {
+	var buf bytes.Buffer
+	err := EncodeScene(&buf, s)
+	done := make(chan struct{})
+	savesMu.Lock()
+	prev := lastSave
+	lastSave = done
+	savesMu.Unlock()
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		if err == nil {
+			err = writeSceneFile(op.Path, buf.Bytes())
+		}
+		if op.Done != nil {
+			op.Done <- err
+		} else if err != nil {
+			log.Printf("Cannot save scene: %s", err)
+		}
+	}()
+	return false
+}
+
+// WaitSaves чекає, доки буде записано файли всіх розпочатих операцій Save.
+func WaitSaves() {
+	savesMu.Lock()
+	prev := lastSave
+	savesMu.Unlock()
+	if prev != nil {
+		<-prev
+	}
+}
@@ -0,0 +1,85 @@
+package painter
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeScene_RoundTrip(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		Fill{Color: color.White},
+		AddRect{ID: "r", X1: 0.1, Y1: 0.2, X2: 0.3, Y2: 0.4, Color: color.RGBA{R: 255, A: 255}, Outline: true},
+		AddShape{ID: "a", X: 0.5, Y: 0.25},
+		AddShape{ID: "b", X: 0.75, Y: 0.5, Color: color.NRGBA{B: 255, A: 128}},
+		Lower{ID: "b"},
+		Animate{ID: "a", X: 1, Y: 1, Duration: time.Second},
+	)
+
+	var buf bytes.Buffer
+	if err := EncodeScene(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeScene(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Scene{
+		BgColor: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		Rects:   []Rectangle{{ID: "r", X1: 0.1, Y1: 0.2, X2: 0.3, Y2: 0.4, Color: color.NRGBA{R: 255, A: 255}, Outline: true, Z: 0}},
+		Shapes: []Shape{
+			{ID: "a", X: 0.5, Y: 0.25, Z: 1},
+			{ID: "b", X: 0.75, Y: 0.5, Color: color.NRGBA{B: 255, A: 128}, Z: -1},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDecodeScene_Errors(t *testing.T) {
+	for _, in := range []string{
+		`not json`,
+		`{"shapes": []}`,
+		`{"version": 0}`,
+		`{"version": 99}`,
+		`{"version": 1, "background": "white"}`,
+		`{"version": 1, "shapes": [{"id": "a"}, {"id": "a"}]}`,
+		`{"version": 1, "rects": [{"x1": 1}]}`,
+	} {
+		if _, err := DecodeScene(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scene.json")
+	var s Scene
+	applyOps(&s, AddShape{ID: "a", X: 0.5, Y: 0.5}, Save{Path: path}, Record{}, Reset{})
+
+	WaitSaves()
+	scene, err := LoadScene(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyOps(&s, Record{}, ReplaceScene{Scene: scene})
+	if len(s.Shapes) != 1 || s.Shapes[0].ID != "a" {
+		t.Fatalf("scene was not loaded: %+v", s)
+	}
+	if _, err := LoadScene(path + ".missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+	applyOps(&s, Undo{})
+	if len(s.Shapes) != 0 {
+		t.Errorf("load should be undoable: %+v", s)
+	}
+}
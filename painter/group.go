@@ -0,0 +1,481 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Transform — перетворення групи: масштабування та поворот навколо точки (PivotX, PivotY), а потім зсув на (X, Y).
+// Координати нормалізовані, але перетворення виконується у пікселях полотна, тож поворот не спотворює фігури на
+// неквадратному полотні. Angle задається в градусах за годинниковою стрілкою.
+type Transform struct {
+	X, Y           float64
+	Angle          float64
+	ScaleX, ScaleY float64
+	PivotX, PivotY float64
+}
+
+// Identity — перетворення, яке нічого не змінює.
+var Identity = Transform{ScaleX: 1, ScaleY: 1}
+
+// matrix повертає перетворення у пікселях полотна b.
+func (tr Transform) matrix(b image.Rectangle) affine {
+	w, h := float64(b.Dx()), float64(b.Dy())
+	px, py := tr.PivotX*w, tr.PivotY*h
+	sin, cos := math.Sincos(tr.Angle * math.Pi / 180)
+	if math.Mod(tr.Angle, 90) == 0 {
+		// Точні значення для прямих кутів, щоб прямокутники лишались вирівняними по пікселях.
+		sin, cos = math.Round(sin), math.Round(cos)
+	}
+	return translate(tr.X*w+px, tr.Y*h+py).
+		mul(affine{a: cos, b: sin, c: -sin, d: cos}).
+		mul(affine{a: tr.ScaleX, d: tr.ScaleY}).
+		mul(translate(-px, -py))
+}
+
+// Group — вузол сцени, який об'єднує фігури, прямокутники та інші групи. Перетворення групи застосовується до всіх
+// її елементів разом з перетвореннями батьківських груп. Групи мають спільний з іншими елементами простір
+// ідентифікаторів і z-індексів; елементи групи малюються разом, на рівні z-індексу самої групи.
+type Group struct {
+	ID        string
+	Parent    string // батьківська група; порожній рядок означає корінь сцени
+	Transform Transform
+	Z         int
+}
+
+// Group повертає індекс групи з указаним ідентифікатором або -1, якщо такої немає.
+func (s *Scene) Group(id string) int {
+	for i := range s.Groups {
+		if s.Groups[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// parent повертає вказівник на батьківську групу елемента з указаним ідентифікатором.
+func (s *Scene) parent(id string) *string {
+	if i := s.Shape(id); i >= 0 {
+		return &s.Shapes[i].Parent
+	}
+	if i := s.Rect(id); i >= 0 {
+		return &s.Rects[i].Parent
+	}
+	if i := s.Primitive(id); i >= 0 {
+		return &s.Primitives[i].Parent
+	}
+	if i := s.Text(id); i >= 0 {
+		return &s.Texts[i].Parent
+	}
+	if i := s.Image(id); i >= 0 {
+		return &s.Images[i].Parent
+	}
+	if i := s.Group(id); i >= 0 {
+		return &s.Groups[i].Parent
+	}
+	return nil
+}
+
+// within повідомляє, чи лежить елемент id у групі group, зокрема через вкладені групи.
+func (s *Scene) within(id, group string) bool {
+	for depth := 0; id != "" && depth <= len(s.Groups); depth++ {
+		if id == group {
+			return true
+		}
+		p := s.parent(id)
+		if p == nil {
+			return false
+		}
+		id = *p
+	}
+	return false
+}
+
+// validParent повертає group, якщо це наявна група, в яку можна перенести елемент id, або корінь сцени.
+func (s *Scene) validParent(id, group string) string {
+	if s.Group(group) < 0 || s.within(group, id) {
+		return ""
+	}
+	return group
+}
+
+// worldMatrix повертає сумарне перетворення елементів групи group на полотні b.
+func (s *Scene) worldMatrix(group string, b image.Rectangle) affine {
+	m := identity
+	for depth := 0; group != "" && depth <= len(s.Groups); depth++ {
+		i := s.Group(group)
+		if i < 0 {
+			break
+		}
+		m = s.Groups[i].Transform.matrix(b).mul(m)
+		group = s.Groups[i].Parent
+	}
+	return m
+}
+
+// LocalPoint переводить точку полотна b у нормалізовані координати всередині групи; для кореня сцени точка не
+// змінюється.
+func (s *Scene) LocalPoint(group string, x, y float64, b image.Rectangle) (float64, float64) {
+	inv, ok := s.worldMatrix(group, b).invert()
+	if !ok {
+		return x, y
+	}
+	px, py := inv.apply(x*float64(b.Dx()), y*float64(b.Dy()))
+	return px / float64(b.Dx()), py / float64(b.Dy())
+}
+
+// node — прямокутник, фігура, примітив, напис або зображення сцени разом з сумарним перетворенням груп, у яких вони лежать.
+type node struct {
+	rect  *Rectangle
+	shape *Shape
+	prim  *Primitive
+	text  *Text
+	image *Image
+	m     affine
+}
+
+// walk обходить дерево сцени у порядку малювання. На кожному рівні елементи йдуть за z-індексом; за однакового
+// індексу прямокутники йдуть першими, потім фігури, примітиви, написи, зображення і нарешті
+// групи.
+func (s *Scene) walk(b image.Rectangle, visit func(n node)) {
+	type child struct {
+		z     int
+		rect  *Rectangle
+		shape *Shape
+		prim  *Primitive
+		text  *Text
+		image *Image
+		group *Group
+	}
+	children := map[string][]child{}
+	for i := range s.Rects {
+		r := &s.Rects[i]
+		children[r.Parent] = append(children[r.Parent], child{z: r.Z, rect: r})
+	}
+	for i := range s.Shapes {
+		sh := &s.Shapes[i]
+		children[sh.Parent] = append(children[sh.Parent], child{z: sh.Z, shape: sh})
+	}
+	for i := range s.Primitives {
+		p := &s.Primitives[i]
+		children[p.Parent] = append(children[p.Parent], child{z: p.Z, prim: p})
+	}
+	for i := range s.Texts {
+		tx := &s.Texts[i]
+		children[tx.Parent] = append(children[tx.Parent], child{z: tx.Z, text: tx})
+	}
+	for i := range s.Images {
+		im := &s.Images[i]
+		children[im.Parent] = append(children[im.Parent], child{z: im.Z, image: im})
+	}
+	for i := range s.Groups {
+		g := &s.Groups[i]
+		children[g.Parent] = append(children[g.Parent], child{z: g.Z, group: g})
+	}
+
+	var visitGroup func(id string, m affine, depth int)
+	visitGroup = func(id string, m affine, depth int) {
+		list := children[id]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].z < list[j].z })
+		for _, c := range list {
+			switch {
+			case c.rect != nil:
+				visit(node{rect: c.rect, m: m})
+			case c.shape != nil:
+				visit(node{shape: c.shape, m: m})
+			case c.prim != nil:
+				visit(node{prim: c.prim, m: m})
+			case c.text != nil:
+				visit(node{text: c.text, m: m})
+			case c.image != nil:
+				visit(node{image: c.image, m: m})
+			case depth < len(s.Groups):
+				visitGroup(c.group.ID, m.mul(c.group.Transform.matrix(b)), depth+1)
+			}
+		}
+	}
+	visitGroup("", identity, 0)
+}
+
+// AddGroup створює порожню групу поверх інших елементів. Якщо ID порожній, групі призначається новий
+// ідентифікатор; якщо група з таким ID вже існує, операція нічого не змінює, а інший елемент з таким ID
+// замінюється групою.
+type AddGroup struct {
+	ID     string
+	Parent string
+}
+
+func (op AddGroup) Do(t screen.Texture, s *Scene) bool {
+	id := op.ID
+	if id == "" {
+		id = s.newID("g")
+	}
+	if s.Group(id) >= 0 {
+		return false
+	}
+	g := Group{ID: id, Parent: s.validParent(id, op.Parent), Transform: Identity}
+	if z := s.z(id); z != nil {
+		g.Z = *z
+		s.remove(id)
+	} else {
+		g.Z = s.topZ()
+	}
+	s.Groups = append(s.Groups, g)
+	render(s, t)
+	return false
+}
+
+// Attach переносить елемент з указаним ID у групу Group або, якщо Group порожній, у корінь сцени. Координати
+// елемента не змінюються, тож він відображається з перетворенням нової групи. Якщо групи Group немає або це сам
+// елемент чи його вкладена група, операція нічого не змінює.
+type Attach struct {
+	ID    string
+	Group string
+}
+
+func (op Attach) Do(t screen.Texture, s *Scene) bool {
+	if p := s.parent(op.ID); p != nil && s.validParent(op.ID, op.Group) == op.Group {
+		*p = op.Group
+	}
+	render(s, t)
+	return false
+}
+
+// Ungroup видаляє групу, переносячи її елементи у батьківську групу.
+type Ungroup struct {
+	ID string
+}
+
+func (op Ungroup) Do(t screen.Texture, s *Scene) bool {
+	i := s.Group(op.ID)
+	if i < 0 {
+		return false
+	}
+	parent := s.Groups[i].Parent
+	for _, p := range s.children(op.ID) {
+		*p = parent
+	}
+	s.Groups = append(s.Groups[:i], s.Groups[i+1:]...)
+	render(s, t)
+	return false
+}
+
+// children повертає вказівники на поле Parent усіх безпосередніх елементів групи.
+func (s *Scene) children(group string) []*string {
+	var res []*string
+	for i := range s.Rects {
+		if s.Rects[i].Parent == group {
+			res = append(res, &s.Rects[i].Parent)
+		}
+	}
+	for i := range s.Shapes {
+		if s.Shapes[i].Parent == group {
+			res = append(res, &s.Shapes[i].Parent)
+		}
+	}
+	for i := range s.Primitives {
+		if s.Primitives[i].Parent == group {
+			res = append(res, &s.Primitives[i].Parent)
+		}
+	}
+	for i := range s.Texts {
+		if s.Texts[i].Parent == group {
+			res = append(res, &s.Texts[i].Parent)
+		}
+	}
+	for i := range s.Images {
+		if s.Images[i].Parent == group {
+			res = append(res, &s.Images[i].Parent)
+		}
+	}
+	for i := range s.Groups {
+		if s.Groups[i].Parent == group {
+			res = append(res, &s.Groups[i].Parent)
+		}
+	}
+	return res
+}
+
+// TranslateGroup задає зсув групи або, якщо Relative, додає (X, Y) до поточного зсуву.
+type TranslateGroup struct {
+	ID       string
+	X, Y     float64
+	Relative bool
+}
+
+func (op TranslateGroup) Do(t screen.Texture, s *Scene) bool {
+	if i := s.Group(op.ID); i >= 0 {
+		tr := &s.Groups[i].Transform
+		if op.Relative {
+			tr.X += op.X
+			tr.Y += op.Y
+		} else {
+			tr.X, tr.Y = op.X, op.Y
+		}
+	}
+	render(s, t)
+	return false
+}
+
+// RotateGroup задає кут повороту групи в градусах або, якщо Relative, повертає її ще на Angle.
+type RotateGroup struct {
+	ID       string
+	Angle    float64
+	Relative bool
+}
+
+func (op RotateGroup) Do(t screen.Texture, s *Scene) bool {
+	if i := s.Group(op.ID); i >= 0 {
+		tr := &s.Groups[i].Transform
+		if op.Relative {
+			tr.Angle = math.Mod(tr.Angle+op.Angle, 360)
+		} else {
+			tr.Angle = op.Angle
+		}
+	}
+	render(s, t)
+	return false
+}
+
+// ScaleGroup задає масштаб групи або, якщо Relative, множить поточний масштаб на (X, Y).
+type ScaleGroup struct {
+	ID       string
+	X, Y     float64
+	Relative bool
+}
+
+func (op ScaleGroup) Do(t screen.Texture, s *Scene) bool {
+	if i := s.Group(op.ID); i >= 0 {
+		tr := &s.Groups[i].Transform
+		if op.Relative {
+			tr.ScaleX *= op.X
+			tr.ScaleY *= op.Y
+		} else {
+			tr.ScaleX, tr.ScaleY = op.X, op.Y
+		}
+	}
+	render(s, t)
+	return false
+}
+
+// PivotGroup задає точку, навколо якої група повертається та масштабується.
+type PivotGroup struct {
+	ID   string
+	X, Y float64
+}
+
+func (op PivotGroup) Do(t screen.Texture, s *Scene) bool {
+	if i := s.Group(op.ID); i >= 0 {
+		s.Groups[i].Transform.PivotX, s.Groups[i].Transform.PivotY = op.X, op.Y
+	}
+	render(s, t)
+	return false
+}
+
+// affine — афінне перетворення x' = a*x + c*y + e, y' = b*x + d*y + f.
+type affine struct {
+	a, b, c, d, e, f float64
+}
+
+var identity = affine{a: 1, d: 1}
+
+func translate(x, y float64) affine {
+	return affine{a: 1, d: 1, e: x, f: y}
+}
+
+// mul повертає перетворення, яке спершу застосовує n, а потім m.
+func (m affine) mul(n affine) affine {
+	return affine{
+		a: m.a*n.a + m.c*n.b,
+		b: m.b*n.a + m.d*n.b,
+		c: m.a*n.c + m.c*n.d,
+		d: m.b*n.c + m.d*n.d,
+		e: m.a*n.e + m.c*n.f + m.e,
+		f: m.b*n.e + m.d*n.f + m.f,
+	}
+}
+
+func (m affine) apply(x, y float64) (float64, float64) {
+	return m.a*x + m.c*y + m.e, m.b*x + m.d*y + m.f
+}
+
+func (m affine) invert() (affine, bool) {
+	det := m.a*m.d - m.b*m.c
+	if det == 0 {
+		return affine{}, false
+	}
+	inv := affine{a: m.d / det, b: -m.b / det, c: -m.c / det, d: m.a / det}
+	inv.e, inv.f = inv.apply(-m.e, -m.f)
+	return inv, true
+}
+
+// axisAligned повідомляє, чи переходять прямокутники у прямокутники зі сторонами вздовж осей.
+func (m affine) axisAligned() bool {
+	return m.b == 0 && m.c == 0
+}
+
+// fillRect заливає прямокутник r, заданий у пікселях до перетворення m. Прямокутник, який після перетворення
+// лишається вирівняним по осях, заливається одним викликом t.Fill, інакше — як многокутник.
+func fillRect(t screen.Texture, r image.Rectangle, m affine, c color.Color, op draw.Op) {
+	if m.axisAligned() {
+		x0, y0 := m.apply(float64(r.Min.X), float64(r.Min.Y))
+		x1, y1 := m.apply(float64(r.Max.X), float64(r.Max.Y))
+		t.Fill(image.Rect(round(x0), round(y0), round(x1), round(y1)), c, op)
+		return
+	}
+	var pts [4][2]float64
+	for i, p := range [4]image.Point{r.Min, {r.Max.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}} {
+		pts[i][0], pts[i][1] = m.apply(float64(p.X), float64(p.Y))
+	}
+	fillPolygon(t, pts[:], c, op)
+}
+
+// fillPolygon заливає опуклий або увігнутий многокутник за правилом парності горизонтальними смугами висотою в один
+// піксель. Піксель зафарбовується, якщо його центр лежить усередині многокутника.
+func fillPolygon(t screen.Texture, pts [][2]float64, c color.Color, op draw.Op) {
+	polygonSpans(pts, t.Bounds(), func(x0, x1, y int) {
+		t.Fill(image.Rect(x0, y, x1, y+1), c, op)
+	})
+}
+
+// polygonSpans викликає span для кожної смуги [x0, x1) рядка y у межах b, з якої складається многокутник за
+// правилом парності, див. fillPolygon.
+func polygonSpans(pts [][2]float64, b image.Rectangle, span func(x0, x1, y int)) {
+	if len(pts) < 3 {
+		return
+	}
+	minY, maxY := pts[0][1], pts[0][1]
+	for _, p := range pts {
+		minY, maxY = min(minY, p[1]), max(maxY, p[1])
+	}
+	y0 := max(int(math.Floor(minY)), b.Min.Y)
+	y1 := min(int(math.Ceil(maxY)), b.Max.Y)
+	var xs []float64
+	for y := y0; y < y1; y++ {
+		yc := float64(y) + 0.5
+		xs = xs[:0]
+		for i, p := range pts {
+			q := pts[(i+1)%len(pts)]
+			if (p[1] <= yc) != (q[1] <= yc) {
+				xs = append(xs, p[0]+(yc-p[1])*(q[0]-p[0])/(q[1]-p[1]))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			x0 := max(int(math.Ceil(xs[i]-0.5)), b.Min.X)
+			x1 := min(int(math.Ceil(xs[i+1]-0.5)), b.Max.X)
+			if x1 > x0 {
+				span(x0, x1, y)
+			}
+		}
+	}
+}
+
+func round(v float64) int {
+	return int(math.Round(v))
+}
@@ -0,0 +1,171 @@
+package painter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+)
+
+func TestAffine(t *testing.T) {
+	m := translate(10, 20).mul(affine{a: 0, b: 1, c: -1, d: 0}).mul(affine{a: 2, d: 3})
+	if x, y := m.apply(1, 1); x != 7 || y != 22 {
+		t.Errorf("apply(1, 1) = (%g, %g), want (7, 22)", x, y)
+	}
+	inv, ok := m.invert()
+	if !ok {
+		t.Fatal("expected an invertible matrix")
+	}
+	if x, y := inv.apply(7, 22); math.Abs(x-1) > 1e-9 || math.Abs(y-1) > 1e-9 {
+		t.Errorf("inverse apply(7, 22) = (%g, %g), want (1, 1)", x, y)
+	}
+	if _, ok := (affine{a: 1}).invert(); ok {
+		t.Error("a degenerate matrix should not be invertible")
+	}
+}
+
+func TestFillPolygon(t *testing.T) {
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(10, 10))
+	img := tx.(*headless.Texture).RGBA()
+	red := color.RGBA{R: 255, A: 255}
+
+	// Трикутник, частина якого виходить за межі текстури.
+	fillPolygon(tx, [][2]float64{{0, 0}, {16, 0}, {0, 16}}, red, draw.Src)
+	for _, tc := range []struct {
+		x, y int
+		want bool
+	}{{0, 0, true}, {9, 0, true}, {5, 5, true}, {0, 9, true}, {9, 9, false}, {8, 8, false}} {
+		if got := img.RGBAAt(tc.x, tc.y) == red; got != tc.want {
+			t.Errorf("pixel (%d, %d) filled = %v, want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestRender_GroupTransform(t *testing.T) {
+	s := Scene{BgColor: color.White}
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(400, 400))
+	img := tx.(*headless.Texture).RGBA()
+	red := color.RGBA{R: 255, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	for _, op := range []Operation{
+		AddGroup{ID: "g"},
+		AddRect{ID: "r", Parent: "g", X1: 0.1, Y1: 0.45, X2: 0.2, Y2: 0.55, Color: red},
+		PivotGroup{ID: "g", X: 0.5, Y: 0.5},
+	} {
+		op.Do(tx, &s)
+	}
+	check := func(name string, x, y int, want color.RGBA) {
+		t.Helper()
+		if got := img.RGBAAt(x, y); got != want {
+			t.Errorf("%s: pixel (%d, %d) = %v, want %v", name, x, y, got, want)
+		}
+	}
+	check("identity", 60, 200, red)
+
+	RotateGroup{ID: "g", Angle: 90}.Do(tx, &s)
+	check("rotate 90", 60, 200, white)
+	check("rotate 90", 200, 60, red)
+
+	RotateGroup{ID: "g", Angle: -45, Relative: true}.Do(tx, &s)
+	check("rotate 45", 101, 101, red)
+	check("rotate 45", 200, 60, white)
+
+	for _, op := range []Operation{
+		RotateGroup{ID: "g"},
+		ScaleGroup{ID: "g", X: 0.5, Y: 0.5},
+		TranslateGroup{ID: "g", X: 0.1, Y: 0},
+	} {
+		op.Do(tx, &s)
+	}
+	// Центр прямокутника (0.15, 0.5) після масштабування навколо (0.5, 0.5) та зсуву.
+	check("scale and translate", 170, 200, red)
+	check("scale and translate", 60, 200, white)
+
+	// Перетворення вкладених груп поєднуються.
+	AddGroup{ID: "outer"}.Do(tx, &s)
+	Attach{ID: "g", Group: "outer"}.Do(tx, &s)
+	TranslateGroup{ID: "outer", X: 0, Y: 0.25}.Do(tx, &s)
+	check("nested", 170, 300, red)
+	check("nested", 170, 200, white)
+}
+
+func TestScene_ShapeAtInGroup(t *testing.T) {
+	var s Scene
+	b := image.Rect(0, 0, 400, 400)
+	applyOps(&s,
+		AddGroup{ID: "g"},
+		AddShape{ID: "a", Parent: "g", X: 0.5, Y: 0.5},
+		TranslateGroup{ID: "g", X: 0.25, Y: 0},
+	)
+	if i := s.ShapeAt(0.75, 0.5, b); i < 0 || s.Shapes[i].ID != "a" {
+		t.Errorf("expected the translated shape at (0.75, 0.5), got %d", i)
+	}
+	if i := s.ShapeAt(0.5, 0.5, b); i >= 0 {
+		t.Errorf("expected no shape at the original position, got %q", s.Shapes[i].ID)
+	}
+	if x, y := s.LocalPoint("g", 0.75, 0.5, b); math.Abs(x-0.5) > 1e-9 || math.Abs(y-0.5) > 1e-9 {
+		t.Errorf("LocalPoint = (%g, %g), want (0.5, 0.5)", x, y)
+	}
+}
+
+func TestGroups_Tree(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddGroup{ID: "a"},
+		AddGroup{ID: "b", Parent: "a"},
+		AddShape{ID: "f", Parent: "b"},
+		AddRect{ID: "r", Parent: "missing"},
+	)
+	if r := s.Rects[s.Rect("r")]; r.Parent != "" {
+		t.Errorf("an unknown parent should put the rect at the root, got %q", r.Parent)
+	}
+
+	applyOps(&s, Attach{ID: "a", Group: "b"}, Attach{ID: "b", Group: "b"})
+	if a, b := s.Groups[s.Group("a")], s.Groups[s.Group("b")]; a.Parent != "" || b.Parent != "a" {
+		t.Errorf("a group must not be attached inside itself: %+v", s.Groups)
+	}
+
+	applyOps(&s, Ungroup{ID: "b"})
+	if s.Group("b") >= 0 || s.Shapes[s.Shape("f")].Parent != "a" {
+		t.Errorf("ungroup should move children to the parent group: %+v %+v", s.Groups, s.Shapes)
+	}
+
+	applyOps(&s, AddGroup{ID: "b", Parent: "a"}, Attach{ID: "r", Group: "b"}, Delete{ID: "a"})
+	if len(s.Groups) != 0 || len(s.Shapes) != 0 || len(s.Rects) != 0 {
+		t.Errorf("deleting a group should delete its descendants: %+v", s)
+	}
+}
+
+func TestSceneFile_Groups(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddGroup{ID: "g"},
+		AddShape{ID: "a", Parent: "g", X: 0.5, Y: 0.5},
+		RotateGroup{ID: "g", Angle: 30},
+		ScaleGroup{ID: "g", X: 2, Y: 1},
+	)
+	var buf bytes.Buffer
+	if err := EncodeScene(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeScene(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Groups, s.Groups) || got.Shapes[0].Parent != "g" {
+		t.Errorf("groups were not restored: %+v %+v", got.Groups, got.Shapes)
+	}
+
+	_, err = DecodeScene(bytes.NewBufferString(`{"version": 2, "groups": [
+		{"id": "a", "parent": "b"}, {"id": "b", "parent": "a"}
+	]}`))
+	if err == nil {
+		t.Error("expected an error for a cycle of groups")
+	}
+}
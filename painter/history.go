@@ -0,0 +1,268 @@
+package painter
+
+import (
+	"image/color"
+	"reflect"
+	"slices"
+	"unsafe"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Обмеження історії сцени. Коли кроків або пам'яті забагато, забуваються найстаріші кроки Undo. Контрольні точки
+// мають окремі обмеження й витісняються лише новими контрольними точками, тож не заважають скасовувати кроки.
+const (
+	MaxHistory         = 100     // кількість кроків Undo
+	MaxHistoryBytes    = 4 << 20 // приблизний обсяг пам'яті станів Undo та Redo, див. sceneSize
+	MaxCheckpoints     = 16      // кількість іменованих контрольних точок
+	MaxCheckpointBytes = 4 << 20 // приблизний обсяг пам'яті контрольних точок
+)
+
+// history зберігає попередні стани сцени для Undo та Redo, а також іменовані контрольні точки.
+type history struct {
+	undo []Scene
+	redo []Scene
+	mark *Scene // стан на початку поточного кроку; потрапляє в undo, лише якщо крок змінив сцену
+
+	checkpoints map[string]Scene
+	names       []string // назви контрольних точок від найстарішої
+}
+
+func (s *Scene) hist() *history {
+	if s.history == nil {
+		s.history = new(history)
+	}
+	return s.history
+}
+
+// flush завершує поточний крок історії.
+func (h *history) flush(s *Scene) {
+	if h.mark != nil && !sameScene(h.mark, s) {
+		h.push(*h.mark)
+	}
+	h.mark = nil
+}
+
+// push додає новий крок Undo, після якого повторювати скасовані кроки вже нічого.
+func (h *history) push(prev Scene) {
+	h.undo = append(h.undo, prev)
+	h.redo = nil
+	h.trim()
+}
+
+// trim забуває найстаріші кроки Undo, доки історія не вкладеться в обмеження.
+func (h *history) trim() {
+	size := h.size()
+	drop := 0
+	for ; drop < len(h.undo) && (len(h.undo)-drop > MaxHistory || size > MaxHistoryBytes); drop++ {
+		size -= sceneSize(&h.undo[drop])
+	}
+	if drop > 0 {
+		clear(h.undo[:drop])
+		h.undo = append(h.undo[:0], h.undo[drop:]...)
+	}
+}
+
+// size повертає приблизний обсяг пам'яті станів Undo та Redo без контрольних точок.
+func (h *history) size() int {
+	n := 0
+	for _, list := range [][]Scene{h.undo, h.redo} {
+		for i := range list {
+			n += sceneSize(&list[i])
+		}
+	}
+	if h.mark != nil {
+		n += sceneSize(h.mark)
+	}
+	return n
+}
+
+// sceneSize оцінює обсяг пам'яті стану сцени без урахування значень кольорів.
+func sceneSize(s *Scene) int {
+	n := int(unsafe.Sizeof(*s)) +
+		len(s.Rects)*int(unsafe.Sizeof(Rectangle{})) +
+		len(s.Shapes)*int(unsafe.Sizeof(Shape{})) +
+		len(s.Primitives)*int(unsafe.Sizeof(Primitive{})) +
+		len(s.Texts)*int(unsafe.Sizeof(Text{})) +
+		len(s.Images)*int(unsafe.Sizeof(Image{})) +
+		len(s.Groups)*int(unsafe.Sizeof(Group{})) +
+		len(s.Tweens)*int(unsafe.Sizeof(Tween{}))
+	n += paintSize(s.BgPaint)
+	for _, r := range s.Rects {
+		n += len(r.ID) + len(r.Parent) + paintSize(r.Paint)
+	}
+	for _, sh := range s.Shapes {
+		n += len(sh.ID) + len(sh.Parent)
+	}
+	for _, p := range s.Primitives {
+		n += len(p.ID) + len(p.Kind) + len(p.Parent) + len(p.Params)*int(unsafe.Sizeof(float64(0)))
+	}
+	for _, tx := range s.Texts {
+		n += len(tx.ID) + len(tx.Text) + len(tx.Font) + len(tx.Parent)
+	}
+	for _, im := range s.Images {
+		n += len(im.ID) + len(im.Asset) + len(im.Parent)
+	}
+	for _, g := range s.Groups {
+		n += len(g.ID) + len(g.Parent)
+	}
+	return n
+}
+
+// paintSize оцінює обсяг пам'яті заливки без урахування значень кольорів.
+func paintSize(p *Paint) int {
+	if p == nil {
+		return 0
+	}
+	return int(unsafe.Sizeof(*p)) + len(p.Kind) + len(p.Params)*int(unsafe.Sizeof(float64(0))) +
+		len(p.Colors)*int(unsafe.Sizeof(color.Color(nil)))
+}
+
+// begin починає новий крок історії з поточного стану сцени.
+func (h *history) begin(s *Scene) {
+	c := s.Clone()
+	h.mark = &c
+}
+
+// step переносить сцену до n-го стану зі стека from, зберігаючи поточні стани у стек to.
+func (h *history) step(s *Scene, n int, from, to *[]Scene) {
+	h.flush(s)
+	for ; n > 0 && len(*from) > 0; n-- {
+		prev := (*from)[len(*from)-1]
+		*from = (*from)[:len(*from)-1]
+		*to = append(*to, s.Clone())
+		prev.history = h
+		*s = prev
+	}
+	h.begin(s)
+}
+
+// checkpoint запам'ятовує поточний стан сцени під назвою name.
+func (h *history) checkpoint(s *Scene, name string) {
+	if h.checkpoints == nil {
+		h.checkpoints = map[string]Scene{}
+	}
+	if i := slices.Index(h.names, name); i >= 0 {
+		h.names = slices.Delete(h.names, i, i+1)
+	}
+	h.checkpoints[name] = s.Clone()
+	h.names = append(h.names, name)
+	h.trimCheckpoints()
+}
+
+// trimCheckpoints забуває найстаріші контрольні точки, доки їхня кількість та обсяг не вкладуться в обмеження.
+// Щойно створена точка лишається, навіть якщо сама перевищує MaxCheckpointBytes.
+func (h *history) trimCheckpoints() {
+	size := 0
+	for _, c := range h.checkpoints {
+		size += sceneSize(&c)
+	}
+	for len(h.names) > 1 && (len(h.names) > MaxCheckpoints || size > MaxCheckpointBytes) {
+		c := h.checkpoints[h.names[0]]
+		size -= sceneSize(&c)
+		delete(h.checkpoints, h.names[0])
+		h.names = slices.Delete(h.names, 0, 1)
+	}
+}
+
+// restore повертає сцену до контрольної точки name окремим кроком, який можна скасувати.
+func (h *history) restore(s *Scene, name string) bool {
+	c, ok := h.checkpoints[name]
+	if !ok {
+		return false
+	}
+	h.flush(s)
+	if !sameScene(&c, s) {
+		h.push(s.Clone())
+	}
+	*s = c.Clone()
+	s.history = h
+	h.begin(s)
+	return true
+}
+
+// sameScene повідомляє, чи однакові сцени без урахування історії.
+func sameScene(a, b *Scene) bool {
+	x, y := *a, *b
+	x.history, y.history = nil, nil
+	return reflect.DeepEqual(x, y)
+}
+
+// Record починає новий крок історії: зміни сцени після нього і до наступного Record, Undo чи Redo скасовуються
+// одним Undo. Крок, який нічого не змінив, в історію не потрапляє.
+type Record struct{}
+
+func (op Record) Do(t screen.Texture, s *Scene) bool {
+	h := s.hist()
+	h.flush(s)
+	h.begin(s)
+	return false
+}
+
+// Undo повертає сцену на N кроків назад; N <= 0 означає один крок.
+type Undo struct {
+	N int
+}
+
+func (op Undo) Do(t screen.Texture, s *Scene) bool {
+	h := s.hist()
+	h.step(s, max(op.N, 1), &h.undo, &h.redo)
+	render(s, t)
+	return false
+}
+
+// Redo повторює N кроків, скасованих Undo; N <= 0 означає один крок.
+type Redo struct {
+	N int
+}
+
+func (op Redo) Do(t screen.Texture, s *Scene) bool {
+	h := s.hist()
+	h.step(s, max(op.N, 1), &h.redo, &h.undo)
+	render(s, t)
+	return false
+}
+
+// Checkpoint запам'ятовує поточний стан сцени під назвою Name, до якого можна повернутись через Restore. Точка з
+// тією ж назвою перезаписується.
+type Checkpoint struct {
+	Name string
+}
+
+func (op Checkpoint) Do(t screen.Texture, s *Scene) bool {
+	s.hist().checkpoint(s, op.Name)
+	return false
+}
+
+// Restore повертає сцену до контрольної точки Name. Якщо такої точки немає, сцена не змінюється.
+type Restore struct {
+	Name string
+}
+
+func (op Restore) Do(t screen.Texture, s *Scene) bool {
+	if s.hist().restore(s, op.Name) {
+		render(s, t)
+	}
+	return false
+}
+
+// ToggleFill змінює колір фону на B, якщо зараз він A, і на A в іншому випадку.
+type ToggleFill struct {
+	A color.Color
+	B color.Color
+}
+
+func (op ToggleFill) Do(t screen.Texture, s *Scene) bool {
+	c := op.A
+	if sameColor(s.BgColor, op.A) {
+		c = op.B
+	}
+	return Fill{Color: c}.Do(t, s)
+}
+
+func sameColor(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return color.RGBA64Model.Convert(a) == color.RGBA64Model.Convert(b)
+}
@@ -0,0 +1,134 @@
+package painter
+
+import (
+	"image/color"
+	"testing"
+	"unsafe"
+)
+
+func TestHistory_UndoRedo(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		Record{}, AddShape{ID: "a", X: 0.1, Y: 0.1},
+		Record{}, MoveShapes{ID: "a", X: 0.2, Y: 0.2}, MoveShapes{ID: "a", X: 0.3, Y: 0.3},
+		Record{}, // Крок без змін не потрапляє в історію.
+		Record{}, Reset{},
+	)
+	if len(s.Shapes) != 0 {
+		t.Fatalf("expected an empty scene, got %+v", s.Shapes)
+	}
+
+	applyOps(&s, Undo{})
+	if i := s.Shape("a"); i < 0 || s.Shapes[i].X != 0.3 {
+		t.Fatalf("reset was not undone: %+v", s.Shapes)
+	}
+	applyOps(&s, Undo{})
+	if a := s.Shapes[s.Shape("a")]; a.X != 0.1 {
+		t.Errorf("moves should be undone as one step: %+v", a)
+	}
+	applyOps(&s, Undo{N: 5})
+	if len(s.Shapes) != 0 {
+		t.Errorf("expected the initial scene, got %+v", s.Shapes)
+	}
+
+	applyOps(&s, Redo{N: 2})
+	if a := s.Shapes[s.Shape("a")]; a.X != 0.3 {
+		t.Errorf("unexpected scene after redo: %+v", a)
+	}
+	applyOps(&s, Record{}, AddShape{ID: "b"}, Redo{})
+	if s.Shape("b") < 0 || len(s.Shapes) != 2 {
+		t.Errorf("a new change should clear redo: %+v", s.Shapes)
+	}
+}
+
+func TestHistory_Limit(t *testing.T) {
+	var s Scene
+	for i := range MaxHistory + 10 {
+		applyOps(&s, Record{}, AddShape{ID: "a", X: float64(i)})
+	}
+	applyOps(&s, Undo{N: 2 * MaxHistory})
+	if a := s.Shapes[s.Shape("a")]; a.X != 9 {
+		t.Errorf("expected the oldest remembered state, got %+v", a)
+	}
+}
+
+func TestToggleFill(t *testing.T) {
+	var s Scene
+	green := color.RGBA{G: 128, A: 255}
+	op := ToggleFill{A: color.White, B: green}
+	for _, want := range []color.Color{color.White, green, color.White} {
+		applyOps(&s, op)
+		if !sameColor(s.BgColor, want) {
+			t.Errorf("got %v, want %v", s.BgColor, want)
+		}
+	}
+}
+
+func TestHistory_Checkpoints(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddShape{ID: "a", X: 0.1}, Checkpoint{Name: "one"},
+		Record{}, MoveShapes{ID: "a", X: 0.2},
+		Checkpoint{Name: "two"},
+		Record{}, Reset{},
+		Restore{Name: "one"},
+	)
+	if a := s.Shapes[s.Shape("a")]; a.X != 0.1 {
+		t.Fatalf("checkpoint was not restored: %+v", a)
+	}
+	applyOps(&s, Undo{})
+	if len(s.Shapes) != 0 {
+		t.Errorf("restore should be undone as one step: %+v", s.Shapes)
+	}
+	applyOps(&s, Restore{Name: "missing"}, Restore{Name: "two"})
+	if a := s.Shapes[s.Shape("a")]; a.X != 0.2 {
+		t.Errorf("unexpected scene: %+v", a)
+	}
+
+	for i := range MaxCheckpoints {
+		applyOps(&s, Checkpoint{Name: string(rune('a' + i))})
+	}
+	if _, ok := s.history.checkpoints["one"]; ok {
+		t.Error("the oldest checkpoint should be evicted")
+	}
+	if len(s.history.checkpoints) != MaxCheckpoints {
+		t.Errorf("expected %d checkpoints, got %d", MaxCheckpoints, len(s.history.checkpoints))
+	}
+}
+
+func TestHistory_MemoryLimit(t *testing.T) {
+	var s Scene
+	big := make([]Shape, MaxHistoryBytes/int(unsafe.Sizeof(Shape{}))/10)
+	applyOps(&s, Record{})
+	s.Shapes = big
+	for i := range 20 {
+		applyOps(&s, Record{}, MoveShapes{X: float64(i)})
+	}
+	if size := s.history.size(); size > MaxHistoryBytes {
+		t.Errorf("history takes %d bytes, limit is %d", size, MaxHistoryBytes)
+	}
+	if n := len(s.history.undo); n == 0 || n >= 20 {
+		t.Errorf("expected some of the steps to be forgotten, got %d", n)
+	}
+}
+
+func TestHistory_CheckpointMemory(t *testing.T) {
+	var s Scene
+	s.Shapes = make([]Shape, MaxCheckpointBytes/int(unsafe.Sizeof(Shape{}))/4)
+	for i := range MaxCheckpoints {
+		applyOps(&s, Checkpoint{Name: string(rune('a' + i))})
+	}
+	size := 0
+	for _, c := range s.history.checkpoints {
+		size += sceneSize(&c)
+	}
+	if _, ok := s.history.checkpoints["p"]; size > MaxCheckpointBytes || !ok {
+		t.Errorf("checkpoints take %d bytes, limit is %d; names %v", size, MaxCheckpointBytes, s.history.names)
+	}
+
+	// Контрольні точки не витісняють кроки Undo.
+	applyOps(&s, Record{}, MoveShapes{X: 0.5}, Record{}, MoveShapes{X: 0.7}, Undo{})
+	if len(s.history.undo) != 1 || !near(s.Shapes[0].X, 0.5) {
+		t.Errorf("undo should work with full checkpoints: %d steps, x = %g", len(s.history.undo), s.Shapes[0].X)
+	}
+}
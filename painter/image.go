@@ -0,0 +1,280 @@
+package painter
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"regexp"
+	"slices"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// Image — растрове зображення сцени з реєстру ресурсів. Точка (X, Y) — лівий верхній кут зображення, а W та H —
+// його ширина й висота в частках розмірів полотна. Якщо W та H нульові, зображення малюється у власному розмірі
+// в пікселях.
+type Image struct {
+	ID     string
+	Asset  string // назва зображення в реєстрі ресурсів, див. StoreAsset
+	X      float64
+	Y      float64
+	W      float64
+	H      float64
+	Alpha  float64 // непрозорість від 0 до 1, на яку множиться прозорість пікселів; 0 означає 1
+	Z      int
+	Parent string // група, в якій лежить зображення; координати задані в її системі
+}
+
+// assetName — допустимі назви ресурсів.
+var assetName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Обмеження реєстру ресурсів: кількість зображень і сумарний розмір їхніх пікселів у байтах.
+const (
+	MaxAssets     = 64
+	MaxAssetBytes = 256 << 20
+)
+
+// ErrAssetsFull повертає StoreAsset, коли нове зображення перевищило б обмеження реєстру ресурсів.
+var ErrAssetsFull = errors.New("painter: asset registry is full")
+
+// Реєстр ресурсів спільний для всіх циклів подій: зображення додаються з обробників HTTP запитів, а читаються під
+// час малювання сцени.
+var (
+	assetsMu    sync.RWMutex
+	assets      = map[string]*image.RGBA{}
+	assetsBytes int // сумарна довжина Pix зображень реєстру
+)
+
+// StoreAsset додає зображення до реєстру ресурсів під назвою name або замінює наявне. Назва може містити лише
+// латинські літери, цифри, '-' та '_'. Реєстр зберігає власну копію зображення. Сцени, які вже використовують
+// ресурс, побачать нове зображення після перемальовування, див. Redraw. Якщо реєстр уже містить MaxAssets зображень
+// або пікселі зайняли б понад MaxAssetBytes байтів, повертається помилка ErrAssetsFull.
+func StoreAsset(name string, img image.Image) error {
+	if !assetName.MatchString(name) {
+		return fmt.Errorf("invalid asset name %q: only letters, digits, '-' and '_' are allowed", name)
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return fmt.Errorf("asset %q is empty", name)
+	}
+	if err := reserveAsset(name, 4*b.Dx()*b.Dy()); err != nil {
+		return err
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	assetsMu.Lock()
+	defer assetsMu.Unlock()
+	// Поки зображення копіювалося, реєстр міг змінитися, тож місце перевіряється знову.
+	if err := checkAsset(name, len(rgba.Pix)); err != nil {
+		return err
+	}
+	if old := assets[name]; old != nil {
+		assetsBytes -= len(old.Pix)
+	}
+	assets[name] = rgba
+	assetsBytes += len(rgba.Pix)
+	return nil
+}
+
+// DeleteAsset видаляє зображення з реєстру ресурсів і повідомляє, чи було воно там. Сцени, які використовують
+// ресурс, перестануть його показувати після перемальовування.
+func DeleteAsset(name string) bool {
+	assetsMu.Lock()
+	defer assetsMu.Unlock()
+	old := assets[name]
+	if old == nil {
+		return false
+	}
+	assetsBytes -= len(old.Pix)
+	delete(assets, name)
+	return true
+}
+
+// reserveAsset перевіряє, чи вміститься в реєстрі зображення розміром size байтів, ще до того, як його копіювати.
+func reserveAsset(name string, size int) error {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	return checkAsset(name, size)
+}
+
+// checkAsset перевіряє, чи вміститься в реєстрі зображення розміром size байтів, яке додається під назвою name або
+// замінює наявне. Викликається під assetsMu.
+func checkAsset(name string, size int) error {
+	count, total := len(assets), assetsBytes+size
+	if old := assets[name]; old != nil {
+		total -= len(old.Pix)
+	} else {
+		count++
+	}
+	if count > MaxAssets {
+		return fmt.Errorf("%w: at most %d assets are allowed", ErrAssetsFull, MaxAssets)
+	}
+	if total > MaxAssetBytes {
+		return fmt.Errorf("%w: asset %q needs %d bytes, %d of %d are used", ErrAssetsFull, name, size, assetsBytes, MaxAssetBytes)
+	}
+	return nil
+}
+
+// Asset повертає зображення з реєстру ресурсів або nil, якщо ресурсу з такою назвою немає. Зображення не можна
+// змінювати.
+func Asset(name string) *image.RGBA {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	return assets[name]
+}
+
+// AssetNames повертає назви ресурсів реєстру за абеткою.
+func AssetNames() []string {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	names := make([]string, 0, len(assets))
+	for name := range assets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Validate перевіряє назву ресурсу, розмір і непрозорість зображення. Наявність ресурсу не перевіряється: сцену
+// можна завантажити раніше, ніж її зображення, а зображення без ресурсу просто не малюється.
+func (im *Image) Validate() error {
+	if !assetName.MatchString(im.Asset) {
+		return fmt.Errorf("invalid asset name %q", im.Asset)
+	}
+	valid := func(v float64) bool { return v >= 0 && !math.IsInf(v, 0) }
+	if !valid(im.W) || !valid(im.H) || (im.W == 0) != (im.H == 0) {
+		return fmt.Errorf("image size must be both zero or both positive, got %g x %g", im.W, im.H)
+	}
+	if !(im.Alpha >= 0 && im.Alpha <= 1) {
+		return fmt.Errorf("image alpha must be in [0, 1], got %g", im.Alpha)
+	}
+	return nil
+}
+
+// Image повертає індекс зображення з указаним ідентифікатором або -1, якщо такого немає.
+func (s *Scene) Image(id string) int {
+	for i := range s.Images {
+		if s.Images[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddImage додає зображення у сцену поверх інших елементів. Якщо ID порожній, зображенню призначається новий
+// ідентифікатор; якщо елемент з таким ID вже існує, зображення займає його місце та z-індекс, а без Parent — і його
+// групу. Зображення з невідповідними параметрами не додається.
+type AddImage Image
+
+func (op AddImage) Do(t screen.Texture, s *Scene) bool {
+	im := Image(op)
+	if im.Validate() != nil {
+		return false
+	}
+	if im.ID == "" {
+		im.ID = s.newID("i")
+	}
+	if im.Parent == "" {
+		if p := s.parent(im.ID); p != nil {
+			im.Parent = *p
+		}
+	}
+	im.Parent = s.validParent(im.ID, im.Parent)
+	if z := s.z(im.ID); z != nil {
+		im.Z = *z
+	} else {
+		im.Z = s.topZ()
+	}
+	if i := s.Image(im.ID); i >= 0 {
+		s.Images[i] = im
+	} else {
+		s.remove(im.ID)
+		s.Images = append(s.Images, im)
+	}
+	render(s, t)
+	return false
+}
+
+// Redraw перемальовує сцену без змін, наприклад після заміни ресурсу в реєстрі.
+type Redraw struct{}
+
+func (op Redraw) Do(t screen.Texture, s *Scene) bool {
+	render(s, t)
+	return false
+}
+
+// drawImage малює зображення з перетворенням m його групи. Зображення масштабується та повертається з білінійною
+// інтерполяцією і накладається на полотно з урахуванням прозорості пікселів та Alpha.
+func drawImage(t screen.Texture, im Image, m affine) {
+	src := Asset(im.Asset)
+	if src == nil {
+		return
+	}
+	b := t.Bounds()
+	sw, sh := float64(src.Rect.Dx()), float64(src.Rect.Dy())
+	w, h := sw, sh
+	if im.W > 0 {
+		w, h = im.W*float64(b.Dx()), im.H*float64(b.Dy())
+	}
+	// toCanvas переводить пікселі ресурсу у пікселі полотна.
+	toCanvas := m.mul(translate(im.X*float64(b.Dx()), im.Y*float64(b.Dy()))).mul(affine{a: w / sw, d: h / sh})
+	if _, ok := toCanvas.invert(); !ok {
+		return
+	}
+	area := image.Rectangle{}
+	for _, p := range [4][2]float64{{0, 0}, {sw, 0}, {sw, sh}, {0, sh}} {
+		x, y := toCanvas.apply(p[0], p[1])
+		area = area.Union(image.Rect(int(math.Floor(x)), int(math.Floor(y)), int(math.Ceil(x))+1, int(math.Ceil(y))+1))
+	}
+	area = area.Intersect(b)
+	if area.Empty() {
+		return
+	}
+	layer := image.NewRGBA(area)
+	s2d := f64.Aff3{toCanvas.a, toCanvas.c, toCanvas.e, toCanvas.b, toCanvas.d, toCanvas.f}
+	draw.BiLinear.Transform(layer, s2d, src, src.Rect, draw.Src, nil)
+
+	alpha := im.Alpha
+	if alpha == 0 {
+		alpha = 1
+	}
+	drawLayer(t, layer, alpha, draw.Over)
+}
+
+// drawLayer переносить зображення layer на текстуру з непрозорістю alpha операцією op. Текстури Loop завжди надають
+// RGBA, див. bufferTexture. Для інших текстур пікселі рядка з однаковим кольором заливаються однією смугою через t.Fill.
+func drawLayer(t screen.Texture, layer *image.RGBA, alpha float64, op draw.Op) {
+	if dst, ok := t.(interface{ RGBA() *image.RGBA }); ok {
+		var mask image.Image
+		if alpha < 1 {
+			mask = image.NewUniform(color.Alpha{A: uint8(alpha*255 + 0.5)})
+		}
+		draw.DrawMask(dst.RGBA(), layer.Rect, layer, layer.Rect.Min, mask, image.Point{}, op)
+		return
+	}
+	k := uint32(alpha*0xffff + 0.5)
+	w := layer.Rect.Dx()
+	for y := range layer.Rect.Dy() {
+		row := layer.Pix[y*layer.Stride : y*layer.Stride+4*w]
+		for x0 := 0; x0 < w; {
+			px := row[4*x0 : 4*x0+4]
+			x1 := x0 + 1
+			for x1 < w && slices.Equal(row[4*x1:4*x1+4], px) {
+				x1++
+			}
+			if px[3] != 0 || op == draw.Src {
+				c := color.RGBA64{
+					R: uint16(uint32(px[0]) * 0x101 * k / 0xffff), G: uint16(uint32(px[1]) * 0x101 * k / 0xffff),
+					B: uint16(uint32(px[2]) * 0x101 * k / 0xffff), A: uint16(uint32(px[3]) * 0x101 * k / 0xffff),
+				}
+				t.Fill(image.Rect(x0, y, x1, y+1).Add(layer.Rect.Min), c, op)
+			}
+			x0 = x1
+		}
+	}
+}
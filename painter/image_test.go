@@ -0,0 +1,155 @@
+package painter
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+)
+
+// storeQuad додає до реєстру ресурс 2x2: червоний, синій, зелений і прозорий пікселі.
+func storeQuad(t *testing.T, name string) {
+	img := image.NewNRGBA(image.Rect(10, 10, 12, 12))
+	img.Set(10, 10, color.NRGBA{R: 255, A: 255})
+	img.Set(11, 10, color.NRGBA{B: 255, A: 255})
+	img.Set(10, 11, color.NRGBA{G: 255, A: 255})
+	if err := StoreAsset(name, img); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DeleteAsset(name) })
+}
+
+func TestAssets_Registry(t *testing.T) {
+	storeQuad(t, "quad")
+	if a := Asset("quad"); a == nil || a.Rect != image.Rect(0, 0, 2, 2) || a.RGBAAt(1, 0) != (color.RGBA{B: 255, A: 255}) {
+		t.Fatalf("unexpected asset %v", a)
+	}
+	if names := AssetNames(); !reflect.DeepEqual(names, []string{"quad"}) {
+		t.Errorf("unexpected names %v", names)
+	}
+	storeQuad(t, "gone")
+	if !DeleteAsset("gone") || DeleteAsset("gone") || Asset("gone") != nil {
+		t.Errorf("asset should be deleted once")
+	}
+	for _, name := range []string{"", "../x", "a b"} {
+		if err := StoreAsset(name, image.NewRGBA(image.Rect(0, 0, 1, 1))); err == nil {
+			t.Errorf("%q: expected error", name)
+		}
+	}
+	if err := StoreAsset("empty", image.NewRGBA(image.Rectangle{})); err == nil {
+		t.Error("expected error for an empty image")
+	}
+
+	// Заміна ресурсу звільняє місце старого зображення, а нове не додається понад MaxAssetBytes.
+	assetsMu.Lock()
+	used := assetsBytes
+	assetsBytes = MaxAssetBytes - 16
+	assetsMu.Unlock()
+	t.Cleanup(func() {
+		assetsMu.Lock()
+		assetsBytes = used
+		assetsMu.Unlock()
+	})
+	if err := StoreAsset("quad", image.NewRGBA(image.Rect(0, 0, 2, 4))); err != nil {
+		t.Errorf("replacing an asset within the limit: %v", err)
+	}
+	if err := StoreAsset("extra", image.NewRGBA(image.Rect(0, 0, 1, 1))); !errors.Is(err, ErrAssetsFull) {
+		t.Errorf("expected ErrAssetsFull, got %v", err)
+	}
+	if Asset("extra") != nil || Asset("quad").Rect.Dy() != 4 {
+		t.Errorf("unexpected assets %v", AssetNames())
+	}
+}
+
+func TestRender_Image(t *testing.T) {
+	storeQuad(t, "quad")
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(100, 100))
+	img := tx.(*headless.Texture).RGBA()
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	renderPrimitives(tx, AddImage{Asset: "quad", X: 0.5, Y: 0.5})
+	if img.RGBAAt(50, 50) != (color.RGBA{R: 255, A: 255}) || img.RGBAAt(51, 50) != (color.RGBA{B: 255, A: 255}) ||
+		img.RGBAAt(51, 51) != white || img.RGBAAt(52, 50) != white {
+		t.Errorf("asset should be drawn in its own size with transparency")
+	}
+
+	renderPrimitives(tx, AddImage{Asset: "quad", X: 0.2, Y: 0.2, W: 0.4, H: 0.4})
+	if got := img.RGBAAt(25, 25); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("scaled asset: expected red, got %v", got)
+	}
+	if got := img.RGBAAt(54, 25); got != (color.RGBA{B: 255, A: 255}) {
+		t.Errorf("scaled asset: expected blue, got %v", got)
+	}
+	if got := img.RGBAAt(65, 25); got != white {
+		t.Errorf("scaled asset should end at 0.6, got %v", got)
+	}
+
+	renderPrimitives(tx, AddImage{Asset: "quad", W: 1, H: 1, Alpha: 0.5})
+	if got := img.RGBAAt(10, 10); got.R != 255 || got.G < 126 || got.G > 129 {
+		t.Errorf("half transparent asset should blend with the background, got %v", got)
+	}
+
+	rotated := []Operation{
+		AddGroup{ID: "g"}, AddImage{Asset: "quad", X: 0.25, Y: 0.25, W: 0.5, H: 0.5, Parent: "g"},
+		PivotGroup{ID: "g", X: 0.5, Y: 0.5}, RotateGroup{ID: "g", Angle: 90},
+	}
+	renderPrimitives(tx, rotated...)
+	if got := img.RGBAAt(70, 30); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("rotated asset: expected red in the top right corner, got %v", got)
+	}
+
+	// Через t.Fill зображення накладається так само, як напряму у зображення.
+	for _, ops := range [][]Operation{{AddImage{Asset: "quad", X: 0.1, Y: 0.1, W: 0.3, H: 0.7, Alpha: 0.3}}, rotated} {
+		renderPrimitives(tx, ops...)
+		want := headless.CloneRGBA(img, nil)
+		renderPrimitives(fillOnly{tx}, ops...)
+		for i := range img.Pix {
+			if d := int(img.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+				t.Fatalf("fill fallback differs at byte %d: %d != %d", i, img.Pix[i], want.Pix[i])
+			}
+		}
+	}
+
+	renderPrimitives(tx, AddImage{Asset: "missing", W: 1, H: 1})
+	if got := img.RGBAAt(50, 50); got != white {
+		t.Errorf("missing asset should not be drawn, got %v", got)
+	}
+}
+
+func TestImage_SceneModel(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddImage{ID: "i", Asset: "logo", X: 0.1, Y: 0.1},
+		AddImage{ID: "bad", Asset: "../logo"},
+		AddImage{ID: "half", Asset: "logo", W: 0.5},
+		AddImage{ID: "alpha", Asset: "logo", Alpha: 2},
+		MoveShapes{ID: "i", X: 0.1, Y: 0.2, Relative: true},
+	)
+	if len(s.Images) != 1 || !near(s.Images[0].X, 0.2) || !near(s.Images[0].Y, 0.3) {
+		t.Fatalf("unexpected images %+v", s.Images)
+	}
+
+	var buf bytes.Buffer
+	applyOps(&s, AddGroup{ID: "g"}, AddImage{ID: "j", Asset: "icon", W: 0.1, H: 0.2, Alpha: 0.5, Parent: "g"})
+	if err := EncodeScene(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeScene(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Images, s.Images) {
+		t.Errorf("expected %+v, got %+v", s.Images, got.Images)
+	}
+	applyOps(&s, Delete{ID: "g"})
+	if len(s.Images) != 1 {
+		t.Errorf("deleting a group should delete its images: %+v", s.Images)
+	}
+	if _, err := DecodeScene(bytes.NewBufferString(`{"version": 5, "images": [{"id": "a", "asset": "x", "w": 1}]}`)); err == nil {
+		t.Error("expected error for an image with one side")
+	}
+}
@@ -0,0 +1,102 @@
+package lang
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// parseColor розбирає колір у форматі #RGB, #RGBA, #RRGGBB, #RRGGBBAA, rgb(r, g, b), rgba(r, g, b, a) або назву
+// кольору CSS.
+func parseColor(s string) (color.Color, error) {
+	if strings.HasPrefix(s, "#") {
+		return parseHexColor(s[1:])
+	}
+	if name, args, ok := strings.Cut(s, "("); ok {
+		return parseColorFunc(strings.ToLower(strings.TrimSpace(name)), args)
+	}
+	if c, ok := colornames.Map[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown color %q", s)
+}
+
+func parseHexColor(hex string) (color.Color, error) {
+	invalid := fmt.Errorf("invalid hex color #%s", hex)
+	switch len(hex) {
+	case 3, 4:
+		// Коротка форма: кожна цифра повторюється двічі.
+		var long strings.Builder
+		for _, r := range hex {
+			long.WriteRune(r)
+			long.WriteRune(r)
+		}
+		hex = long.String()
+	case 6, 8:
+	default:
+		return nil, invalid
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, invalid
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// parseColorFunc розбирає rgb(...) та rgba(...). Канали задаються числами 0..255 або відсотками, прозорість —
+// числом 0..1 або відсотками.
+func parseColorFunc(name, args string) (color.Color, error) {
+	args, ok := strings.CutSuffix(strings.TrimSpace(args), ")")
+	if !ok {
+		return nil, fmt.Errorf("%s color is missing the closing parenthesis", name)
+	}
+	parts := strings.Split(args, ",")
+	var want int
+	switch name {
+	case "rgb":
+		want = 3
+	case "rgba":
+		want = 4
+	default:
+		return nil, fmt.Errorf("unknown color function %q", name)
+	}
+	if len(parts) != want {
+		return nil, fmt.Errorf("%s color requires %d components, got %d", name, want, len(parts))
+	}
+
+	var ch [4]uint8
+	ch[3] = 255
+	for i, part := range parts {
+		limit := 255.0
+		if i == 3 {
+			limit = 1
+		}
+		v, err := parseChannel(strings.TrimSpace(part), limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s component %d: %v", name, i+1, err)
+		}
+		ch[i] = uint8(v*255/limit + 0.5)
+	}
+	return color.NRGBA{R: ch[0], G: ch[1], B: ch[2], A: ch[3]}, nil
+}
+
+func parseChannel(s string, limit float64) (float64, error) {
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 || v > 100 {
+			return 0, fmt.Errorf("invalid percentage %q", s)
+		}
+		return v / 100 * limit, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || v > limit {
+		return 0, fmt.Errorf("value %q must be in [0, %g]", s, limit)
+	}
+	return v, nil
+}
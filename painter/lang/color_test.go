@@ -0,0 +1,37 @@
+package lang
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.Color
+	}{
+		{"#f00", color.NRGBA{R: 255, A: 255}},
+		{"#00ff0080", color.NRGBA{G: 255, A: 128}},
+		{"#1234", color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0x44}},
+		{"rgb(0, 128, 255)", color.NRGBA{G: 128, B: 255, A: 255}},
+		{"rgba(255,0,0,0.5)", color.NRGBA{R: 255, A: 128}},
+		{"RGBA(100%, 0%, 0%, 50%)", color.NRGBA{R: 255, A: 128}},
+		{"CornflowerBlue", color.RGBA{R: 100, G: 149, B: 237, A: 255}},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.in)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.in, tt.want, got)
+		}
+	}
+
+	for _, in := range []string{"#12", "#ggg", "rgb(1,2)", "rgba(1,2,3,2)", "rgb(300,0,0)", "hsl(0,0,0)", "rgb(1,2,3", "nocolor"} {
+		if _, err := parseColor(in); err == nil {
+			t.Errorf("%q: expected error, got none", in)
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package lang
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/DmytroHalai/kpi-3/painter"
+)
+
+// argKind визначає, як розбирається значення аргументу команди.
+type argKind int
+
+const (
+	coordArg    argKind = iota // координата у частках розміру полотна
+	colorArg                   // колір, див. parseColor
+	durationArg                // тривалість: 2s, 500ms або число секунд
+	countArg                   // ціле додатне число
+	nameArg                    // назва: рядок або слово без лапок
+	pointsArg                  // пари координат x y; лише останній параметр, забирає всі решту аргументів
+	textArg                    // непорожній текст: рядок, слово без лапок або число
+	valuesArg                  // числа та кольори; лише останній параметр, забирає всі решту аргументів
+)
+
+func (k argKind) String() string {
+	switch k {
+	case colorArg:
+		return "color"
+	case durationArg:
+		return "duration"
+	case countArg:
+		return "count"
+	case nameArg:
+		return "name"
+	case pointsArg:
+		return "points"
+	case textArg:
+		return "text"
+	case valuesArg:
+		return "values"
+	}
+	return "number"
+}
+
+type param struct {
+	name     string
+	kind     argKind
+	optional bool   // необов'язкові параметри йдуть в кінці списку
+	prefix   string // слово, яке в текстовому скрипті стоїть перед аргументом, наприклад to у "animate to 0.5 0.5"
+	min      int    // найменша кількість точок параметра pointsArg або значень параметра valuesArg
+}
+
+// variadic повідомляє, чи забирає параметр усі решту аргументів.
+func (p param) variadic() bool {
+	return p.kind == pointsArg || p.kind == valuesArg
+}
+
+// command описує команду мови: її позиційні параметри, іменовані опції та побудову операції з розібраних значень.
+// Текстові скрипти та JSON API використовують один і той самий опис, тому будують однакові операції.
+type command struct {
+	params   []param
+	options  map[string][]string // допустимі значення опції; nil означає довільне значення
+	optKinds map[string]argKind  // вид значення опції: coordArg або colorArg; решта опцій — рядки
+	require  []string            // обов'язкові опції
+	build    func(a *cmdArgs) (painter.Operation, error)
+}
+
+// arity повертає мінімальну та максимальну кількість позиційних аргументів; hi < 0 означає, що найбільшої немає.
+func (c *command) arity() (lo, hi int) {
+	hi = len(c.params)
+	for _, p := range c.params {
+		switch {
+		case p.kind == pointsArg:
+			lo += 2 * p.min
+			hi = -1
+		case p.kind == valuesArg:
+			lo += p.min
+			hi = -1
+		case !p.optional:
+			lo++
+		}
+	}
+	return lo, hi
+}
+
+// paramAt повертає параметр, якому відповідає i-й позиційний аргумент.
+func (c *command) paramAt(i int) (param, bool) {
+	if i < len(c.params) {
+		return c.params[i], true
+	}
+	if n := len(c.params); n > 0 && c.params[n-1].variadic() {
+		return c.params[n-1], true
+	}
+	return param{}, false
+}
+
+// cmdArgs містить розібрані значення аргументів команди.
+type cmdArgs struct {
+	coords    map[string]float64
+	colors    map[string]color.Color
+	durations map[string]time.Duration
+	counts    map[string]int
+	names     map[string]string
+	pointSets map[string][]float64
+	texts     map[string]string
+	values    map[string][]any // числа float64 та кольори color.Color
+	opts      map[string]string
+	optNums   map[string]float64 // значення опцій виду coordArg
+
+	parser *Parser
+}
+
+func (a *cmdArgs) coord(name string) float64          { return a.coords[name] }
+func (a *cmdArgs) color(name string) color.Color      { return a.colors[name] }
+func (a *cmdArgs) duration(name string) time.Duration { return a.durations[name] }
+func (a *cmdArgs) count(name string) int              { return a.counts[name] }
+func (a *cmdArgs) name(name string) string            { return a.names[name] }
+func (a *cmdArgs) points(name string) []float64       { return a.pointSets[name] }
+func (a *cmdArgs) opt(name string) string             { return a.opts[name] }
+
+var commands map[string]*command
+
+func init() {
+	simple := func(op painter.Operation) *command {
+		return &command{build: func(*cmdArgs) (painter.Operation, error) { return op, nil }}
+	}
+	byID := func(build func(id string) painter.Operation) *command {
+		return &command{
+			options: map[string][]string{"id": nil},
+			require: []string{"id"},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return build(a.opt("id")), nil
+			},
+		}
+	}
+
+	// shape описує команду примітиву виду kind: значення параметрів передаються примітиву в порядку params, а колір
+	// заливки, колір і товщина контуру задаються опціями fill, stroke та width.
+	shape := func(kind string, params ...param) *command {
+		return &command{
+			params:   params,
+			options:  map[string][]string{"id": nil, "group": nil, "fill": nil, "stroke": nil, "width": nil},
+			optKinds: map[string]argKind{"fill": colorArg, "stroke": colorArg, "width": coordArg},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				p := painter.Primitive{ID: a.opt("id"), Kind: kind, Parent: a.opt("group")}
+				for _, prm := range params {
+					switch prm.kind {
+					case pointsArg:
+						p.Params = append(p.Params, a.points(prm.name)...)
+					case countArg:
+						if n, ok := a.counts[prm.name]; ok {
+							p.Params = append(p.Params, float64(n))
+						}
+					default:
+						if v, ok := a.coords[prm.name]; ok {
+							p.Params = append(p.Params, v)
+						}
+					}
+				}
+				var err error
+				if p.Fill, err = optColor(a, "fill"); err != nil {
+					return nil, err
+				}
+				if p.Stroke, err = optColor(a, "stroke"); err != nil {
+					return nil, err
+				}
+				if p.Width, err = optNumber(a, "width"); err != nil {
+					return nil, err
+				}
+				if err := p.Validate(); err != nil {
+					return nil, err
+				}
+				return painter.AddPrimitive(p), nil
+			},
+		}
+	}
+
+	commands = map[string]*command{
+		"white":   simple(painter.WhiteFill()),
+		"green":   simple(painter.GreenFill()),
+		"update":  simple(painter.UpdateOp),
+		"reset":   simple(painter.ResetOp()),
+		"bgclear": simple(painter.ClearRects{}),
+
+		"fill": {
+			params: []param{{name: "color", kind: colorArg}},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.Fill{Color: a.color("color")}, nil
+			},
+		},
+
+		"bgrect": {
+			params: []param{
+				{name: "x1"}, {name: "y1"}, {name: "x2"}, {name: "y2"},
+				{name: "color", kind: colorArg, optional: true},
+			},
+			options: map[string][]string{"id": nil, "mode": {"fill", "outline"}, "group": nil},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.AddRect{
+					ID:      a.opt("id"),
+					Parent:  a.opt("group"),
+					X1:      a.coord("x1"),
+					Y1:      a.coord("y1"),
+					X2:      a.coord("x2"),
+					Y2:      a.coord("y2"),
+					Color:   a.color("color"),
+					Outline: a.opt("mode") == "outline",
+				}, nil
+			},
+		},
+
+		"figure": {
+			params:  []param{{name: "x"}, {name: "y"}, {name: "color", kind: colorArg, optional: true}},
+			options: map[string][]string{"id": nil, "group": nil},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.AddShape{
+					ID:     a.opt("id"),
+					X:      a.coord("x"),
+					Y:      a.coord("y"),
+					Color:  a.color("color"),
+					Parent: a.opt("group"),
+				}, nil
+			},
+		},
+
+		// Примітиви: довжини (радіуси, товщина контуру) задаються в частках меншої сторони полотна.
+		"circle":   shape("circle", param{name: "x"}, param{name: "y"}, param{name: "r"}),
+		"ellipse":  shape("ellipse", param{name: "x"}, param{name: "y"}, param{name: "rx"}, param{name: "ry"}),
+		"line":     shape("line", param{name: "x1"}, param{name: "y1"}, param{name: "x2"}, param{name: "y2"}),
+		"polyline": shape("polyline", param{name: "points", kind: pointsArg, min: 2}),
+		"polygon":  shape("polygon", param{name: "points", kind: pointsArg, min: 3}),
+		"triangle": shape("triangle",
+			param{name: "x1"}, param{name: "y1"}, param{name: "x2"}, param{name: "y2"}, param{name: "x3"}, param{name: "y3"}),
+		// n — кількість променів, inner — відношення внутрішнього радіуса до зовнішнього.
+		"star": shape("star", param{name: "x"}, param{name: "y"}, param{name: "r"},
+			param{name: "n", kind: countArg, optional: true}, param{name: "inner", optional: true}),
+		"roundrect": shape("roundrect",
+			param{name: "x1"}, param{name: "y1"}, param{name: "x2"}, param{name: "y2"}, param{name: "radius"}),
+
+		// Текст може містити кілька рядків, розділених \n; spacing — відстань між рядками в кеглях.
+		"text": {
+			params: []param{
+				{name: "x"}, {name: "y"}, {name: "size"}, {name: "text", kind: textArg},
+				{name: "color", kind: colorArg, optional: true},
+			},
+			options: map[string][]string{
+				"id": nil, "group": nil, "align": {"left", "center", "right"}, "font": painter.FontNames(), "spacing": nil,
+			},
+			optKinds: map[string]argKind{"spacing": coordArg},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				t := painter.Text{
+					ID:     a.opt("id"),
+					X:      a.coord("x"),
+					Y:      a.coord("y"),
+					Size:   a.coord("size"),
+					Text:   a.texts["text"],
+					Color:  a.color("color"),
+					Font:   a.opt("font"),
+					Parent: a.opt("group"),
+				}
+				var err error
+				if s := a.opt("align"); s != "" {
+					if t.Align, err = painter.ParseAlign(s); err != nil {
+						return nil, err
+					}
+				}
+				if t.Spacing, err = optNumber(a, "spacing"); err != nil {
+					return nil, err
+				}
+				if err := t.Validate(); err != nil {
+					return nil, err
+				}
+				return painter.AddText(t), nil
+			},
+		},
+
+		// Зображення з реєстру ресурсів; без w та h воно малюється у власному розмірі, alpha — непрозорість.
+		"image": {
+			params: []param{
+				{name: "name", kind: nameArg}, {name: "x"}, {name: "y"},
+				{name: "w", optional: true}, {name: "h", optional: true},
+			},
+			options:  map[string][]string{"id": nil, "group": nil, "alpha": nil},
+			optKinds: map[string]argKind{"alpha": coordArg},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				im := painter.Image{
+					ID:     a.opt("id"),
+					Asset:  a.name("name"),
+					X:      a.coord("x"),
+					Y:      a.coord("y"),
+					W:      a.coord("w"),
+					H:      a.coord("h"),
+					Parent: a.opt("group"),
+				}
+				if _, ok := a.coords["h"]; !ok && im.W != 0 {
+					return nil, fmt.Errorf("image requires both w and h")
+				}
+				var err error
+				if im.Alpha, err = optNumber(a, "alpha"); err != nil {
+					return nil, err
+				}
+				if err := im.Validate(); err != nil {
+					return nil, err
+				}
+				if painter.Asset(im.Asset) == nil {
+					return nil, fmt.Errorf("unknown asset %q", im.Asset)
+				}
+				return painter.AddImage(im), nil
+			},
+		},
+
+		"move": {
+			params:  []param{{name: "x"}, {name: "y"}},
+			options: map[string][]string{"id": nil, "mode": {"abs", "rel"}},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.MoveShapes{ID: a.opt("id"), X: a.coord("x"), Y: a.coord("y"), Relative: a.opt("mode") == "rel"}, nil
+			},
+		},
+
+		"animate": {
+			params: []param{
+				{name: "x", prefix: "to"}, {name: "y"},
+				{name: "duration", kind: durationArg, prefix: "over"},
+			},
+			options: map[string][]string{"figure": nil, "ease": nil},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				easing, err := parseEasing(a.opt("ease"))
+				if err != nil {
+					return nil, err
+				}
+				return painter.Animate{
+					ID:       a.opt("figure"),
+					X:        a.coord("x"),
+					Y:        a.coord("y"),
+					Duration: a.duration("duration"),
+					Easing:   easing,
+				}, nil
+			},
+		},
+
+		"delete": byID(func(id string) painter.Operation { return painter.Delete{ID: id} }),
+		"raise":  byID(func(id string) painter.Operation { return painter.Raise{ID: id} }),
+		"lower":  byID(func(id string) painter.Operation { return painter.Lower{ID: id} }),
+
+		// Групи: координати елементів групи задаються в її системі, а перетворення групи застосовується до всіх них.
+		"group": {
+			options: map[string][]string{"id": nil, "parent": nil},
+			require: []string{"id"},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.AddGroup{ID: a.opt("id"), Parent: a.opt("parent")}, nil
+			},
+		},
+		"attach": {
+			options: map[string][]string{"id": nil, "group": nil},
+			require: []string{"id", "group"},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.Attach{ID: a.opt("id"), Group: a.opt("group")}, nil
+			},
+		},
+		"detach":  byID(func(id string) painter.Operation { return painter.Attach{ID: id} }),
+		"ungroup": byID(func(id string) painter.Operation { return painter.Ungroup{ID: id} }),
+		"translate": {
+			params:  []param{{name: "x"}, {name: "y"}},
+			options: map[string][]string{"id": nil, "mode": {"abs", "rel"}},
+			require: []string{"id"},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.TranslateGroup{ID: a.opt("id"), X: a.coord("x"), Y: a.coord("y"), Relative: a.opt("mode") == "rel"}, nil
+			},
+		},
+		"rotate": {
+			params:  []param{{name: "angle"}},
+			options: map[string][]string{"id": nil, "mode": {"abs", "rel"}},
+			require: []string{"id"},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.RotateGroup{ID: a.opt("id"), Angle: a.coord("angle"), Relative: a.opt("mode") == "rel"}, nil
+			},
+		},
+		// Без sy масштаб однаковий за обома осями.
+		"scale": {
+			params:  []param{{name: "sx"}, {name: "sy", optional: true}},
+			options: map[string][]string{"id": nil, "mode": {"abs", "rel"}},
+			require: []string{"id"},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				sx := a.coord("sx")
+				sy, ok := a.coords["sy"]
+				if !ok {
+					sy = sx
+				}
+				return painter.ScaleGroup{ID: a.opt("id"), X: sx, Y: sy, Relative: a.opt("mode") == "rel"}, nil
+			},
+		},
+		"pivot": {
+			params:  []param{{name: "x"}, {name: "y"}},
+			options: map[string][]string{"id": nil},
+			require: []string{"id"},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.PivotGroup{ID: a.opt("id"), X: a.coord("x"), Y: a.coord("y")}, nil
+			},
+		},
+
+		// Без аргументу undo та redo скасовують або повторюють один крок.
+		"undo": {
+			params: []param{{name: "n", kind: countArg, optional: true}},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.Undo{N: a.count("n")}, nil
+			},
+		},
+		"redo": {
+			params: []param{{name: "n", kind: countArg, optional: true}},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.Redo{N: a.count("n")}, nil
+			},
+		},
+		"checkpoint": {
+			params: []param{{name: "name", kind: nameArg}},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.Checkpoint{Name: a.name("name")}, nil
+			},
+		},
+		"restore": {
+			params: []param{{name: "name", kind: nameArg}},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.Restore{Name: a.name("name")}, nil
+			},
+		},
+
+		"save": {
+			params: []param{{name: "name", kind: nameArg}},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				path, err := a.parser.scenePath(a.name("name"))
+				return painter.Save{Path: path}, err
+			},
+		},
+		"load": {
+			params: []param{{name: "name", kind: nameArg}},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				path, err := a.parser.scenePath(a.name("name"))
+				return loadScene{Path: path}, err
+			},
+		},
+
+		// Градієнт або візерунок фону, а з id — прямокутника bgrect. Спершу йдуть числові параметри заливки, потім її
+		// кольори: bg linear 0 0 1 1 #fff #000, bg stripes 0.05 45 white gray.
+		"bg": {
+			params:  []param{{name: "kind", kind: nameArg}, {name: "args", kind: valuesArg, min: 3}},
+			options: map[string][]string{"id": nil},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				p := painter.Paint{Kind: a.name("kind")}
+				for _, v := range a.values["args"] {
+					switch v := v.(type) {
+					case float64:
+						if len(p.Colors) > 0 {
+							return nil, fmt.Errorf("%s parameters must come before colors", p.Kind)
+						}
+						p.Params = append(p.Params, v)
+					case color.Color:
+						p.Colors = append(p.Colors, v)
+					}
+				}
+				if err := p.Validate(); err != nil {
+					return nil, err
+				}
+				return painter.FillPaint{ID: a.opt("id"), Paint: p}, nil
+			},
+		},
+
+		"color": {
+			params:  []param{{name: "color", kind: colorArg}},
+			options: map[string][]string{"id": nil},
+			require: []string{"id"},
+			build: func(a *cmdArgs) (painter.Operation, error) {
+				return painter.Recolor{ID: a.opt("id"), Color: a.color("color")}, nil
+			},
+		},
+	}
+}
+
+// optColor розбирає колір з опції name; без опції повертає nil.
+func optColor(a *cmdArgs, name string) (color.Color, error) {
+	s := a.opt(name)
+	if s == "" {
+		return nil, nil
+	}
+	c, err := parseColor(s)
+	if err != nil {
+		return nil, fmt.Errorf("option %s: %w", name, err)
+	}
+	return c, nil
+}
+
+// optNumber розбирає додатне число з опції name; без опції повертає 0.
+func optNumber(a *cmdArgs, name string) (float64, error) {
+	v, ok := a.optNums[name]
+	if !ok {
+		return 0, nil
+	}
+	if !(v > 0) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("option %s must be a positive number, got %g", name, v)
+	}
+	return v, nil
+}
+
+// setOption перевіряє та запам'ятовує значення іменованої опції, задане текстом. Значення числових опцій
+// розбираються одразу.
+func (c *command) setOption(a *cmdArgs, key, value string) error {
+	if err := c.checkOption(a, key); err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("option %s requires a value", key)
+	}
+	if allowed := c.options[key]; allowed != nil && !slices.Contains(allowed, value) {
+		return fmt.Errorf("option %s must be one of %v, got %q", key, allowed, value)
+	}
+	if c.optKind(key) == coordArg {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("option %s must be a number, got %q", key, value)
+		}
+		a.optNums[key] = v
+	}
+	a.opts[key] = value
+	return nil
+}
+
+// setNumberOption запам'ятовує значення числової опції, задане числом, як у JSON запитах.
+func (c *command) setNumberOption(a *cmdArgs, key string, v float64) error {
+	if err := c.checkOption(a, key); err != nil {
+		return err
+	}
+	a.optNums[key] = v
+	a.opts[key] = strconv.FormatFloat(v, 'g', -1, 64)
+	return nil
+}
+
+// optKind повертає вид значення опції; опції без запису в optKinds — рядки, nameArg.
+func (c *command) optKind(key string) argKind {
+	if k, ok := c.optKinds[key]; ok {
+		return k
+	}
+	return nameArg
+}
+
+// checkOption перевіряє, що команда має опцію key і її ще не задано.
+func (c *command) checkOption(a *cmdArgs, key string) error {
+	if _, ok := c.options[key]; !ok {
+		return fmt.Errorf("unknown option %q", key)
+	}
+	if _, dup := a.opts[key]; dup {
+		return fmt.Errorf("option %s is given twice", key)
+	}
+	return nil
+}
+
+// size оцінює обсяг даних, які команда передає в операцію: точок, значень і тексту.
+func (a *cmdArgs) size() int {
+	n := 0
+	for _, v := range a.pointSets {
+		n += len(v) * int(unsafe.Sizeof(float64(0)))
+	}
+	for _, v := range a.values {
+		n += len(v) * int(unsafe.Sizeof(any(nil)))
+	}
+	for _, s := range a.texts {
+		n += len(s)
+	}
+	return n
+}
+
+func newCmdArgs(p *Parser) *cmdArgs {
+	return &cmdArgs{
+		parser:    p,
+		coords:    map[string]float64{},
+		colors:    map[string]color.Color{},
+		durations: map[string]time.Duration{},
+		counts:    map[string]int{},
+		names:     map[string]string{},
+		pointSets: map[string][]float64{},
+		texts:     map[string]string{},
+		values:    map[string][]any{},
+		opts:      map[string]string{},
+		optNums:   map[string]float64{},
+	}
+}
+
+// missingOption повертає назву першої обов'язкової опції, якої немає серед аргументів.
+func (c *command) missingOption(a *cmdArgs) (string, bool) {
+	for _, key := range c.require {
+		if _, ok := a.opts[key]; !ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// usage повертає рядок з описом синтаксису команди.
+func (c *command) usage(name string) string {
+	parts := []string{name}
+	keys := make([]string, 0, len(c.options))
+	for key := range c.options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		opt := key + "=<" + key + ">"
+		if allowed := c.options[key]; allowed != nil {
+			opt = key + "=" + strings.Join(allowed, "|")
+		}
+		if !slices.Contains(c.require, key) {
+			opt = "[" + opt + "]"
+		}
+		parts = append(parts, opt)
+	}
+	for _, p := range c.params {
+		if p.kind == pointsArg {
+			for i := 1; i <= p.min; i++ {
+				parts = append(parts, fmt.Sprintf("<x%d> <y%d>", i, i))
+			}
+			parts = append(parts, "[<x> <y> ...]")
+			continue
+		}
+		if p.kind == valuesArg {
+			parts = append(parts, "<"+p.name+"> ...")
+			continue
+		}
+		arg := "<" + p.name + ">"
+		if p.prefix != "" {
+			arg = p.prefix + " " + arg
+		}
+		if p.optional {
+			arg = "[" + arg + "]"
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// sceneName — допустимі назви файлів сцен для save та load. Інші символи, зокрема роздільники шляху, заборонені,
+// щоб скрипт не міг писати за межі Parser.SceneDir.
+var sceneName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// scenePath повертає шлях до файлу сцени з указаною назвою.
+func (p *Parser) scenePath(name string) (string, error) {
+	if !sceneName.MatchString(name) {
+		return "", fmt.Errorf("invalid scene name %q: only letters, digits, '-' and '_' are allowed", name)
+	}
+	return filepath.Join(p.SceneDir, name+".json"), nil
+}
+
+// easings — назви функцій плавності опції ease.
+var easings = map[string]painter.CubicBezier{
+	"linear":      painter.Linear,
+	"ease":        painter.Ease,
+	"ease-in":     painter.EaseIn,
+	"in":          painter.EaseIn,
+	"ease-out":    painter.EaseOut,
+	"out":         painter.EaseOut,
+	"ease-in-out": painter.EaseInOut,
+	"in-out":      painter.EaseInOut,
+}
+
+// parseEasing розбирає назву функції плавності або cubic-bezier(x1, y1, x2, y2). Порожній рядок означає linear.
+func parseEasing(s string) (painter.CubicBezier, error) {
+	if s == "" {
+		return painter.Linear, nil
+	}
+	if e, ok := easings[s]; ok {
+		return e, nil
+	}
+	args, ok := strings.CutPrefix(s, "cubic-bezier(")
+	if !ok || !strings.HasSuffix(args, ")") {
+		return painter.CubicBezier{}, fmt.Errorf("unknown easing %q", s)
+	}
+	parts := strings.Split(strings.TrimSuffix(args, ")"), ",")
+	if len(parts) != 4 {
+		return painter.CubicBezier{}, fmt.Errorf("cubic-bezier requires 4 numbers, got %d", len(parts))
+	}
+	var v [4]float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return painter.CubicBezier{}, fmt.Errorf("invalid cubic-bezier number %q", part)
+		}
+		if i%2 == 0 && (n < 0 || n > 1) {
+			return painter.CubicBezier{}, fmt.Errorf("cubic-bezier x values must be in [0, 1], got %g", n)
+		}
+		v[i] = n
+	}
+	return painter.CubicBezier{X1: v[0], Y1: v[1], X2: v[2], Y2: v[3]}, nil
+}
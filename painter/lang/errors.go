@@ -0,0 +1,41 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError описує помилку в команді скрипта. Line та Column рахуються з 1; Column вказує на символ, з якого
+// починається помилкове слово.
+type ParseError struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Command  string `json:"command,omitempty"`
+	Expected string `json:"expected,omitempty"` // очікувана кількість аргументів команди
+	Usage    string `json:"usage,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "line %d, column %d: ", e.Line, e.Column)
+	if e.Command != "" {
+		fmt.Fprintf(&b, "%s: ", e.Command)
+	}
+	b.WriteString(e.Message)
+	if e.Usage != "" {
+		fmt.Fprintf(&b, " (usage: %s)", e.Usage)
+	}
+	return b.String()
+}
+
+// ParseErrors — усі помилки, знайдені у скрипті.
+type ParseErrors []*ParseError
+
+func (es ParseErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
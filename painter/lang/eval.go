@@ -0,0 +1,551 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// value — значення виразу: float64, percent, bool, string, time.Duration або color.Color.
+type value = any
+
+// percent — число, записане з %, наприклад 50%. У числовому контексті дорівнює частці (0.5).
+type percent float64
+
+// errReported позначає значення змінної, обчислення якої вже завершилось помилкою, щоб не повідомляти про неї
+// вдруге при кожному використанні.
+var errReported = errors.New("error already reported")
+
+// env зберігає значення змінних скрипта.
+type env struct {
+	vars   map[string]value
+	parent *env
+	x      *executor // виконавець, обмеження якого діють під час обчислення; успадковується від parent
+}
+
+func newEnv(parent *env) *env {
+	e := &env{vars: map[string]value{}, parent: parent}
+	if parent != nil {
+		e.x = parent.x
+	}
+	return e
+}
+
+func (e *env) lookup(name string) (value, bool) {
+	for ; e != nil; e = e.parent {
+		if v, ok := e.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// constants доступні у кожному скрипті як змінні: cx та cy — центр полотна.
+var constants = map[string]value{
+	"pi":    math.Pi,
+	"cx":    0.5,
+	"cy":    0.5,
+	"true":  true,
+	"false": false,
+}
+
+func literalValue(t token) (value, error) {
+	switch t.kind {
+	case tokNumber, tokPercent:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		if t.kind == tokPercent {
+			return percent(v), nil
+		}
+		return v, nil
+	case tokDuration:
+		num, unit := strings.TrimSuffix(t.text, "s"), time.Second
+		if strings.HasSuffix(num, "m") {
+			num, unit = strings.TrimSuffix(num, "m"), time.Millisecond
+		}
+		v, err := strconv.ParseFloat(num, 64)
+		if err != nil || v*float64(unit) > math.MaxInt64 {
+			return nil, fmt.Errorf("invalid duration %q", t.text)
+		}
+		return time.Duration(v * float64(unit)), nil
+	case tokColor:
+		return parseColor(t.text)
+	default:
+		return t.text, nil
+	}
+}
+
+// number перетворює значення на число.
+func number(v value) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case percent:
+		return float64(v) / 100, true
+	}
+	return 0, false
+}
+
+func typeName(v value) string {
+	switch v.(type) {
+	case float64, percent:
+		return "number"
+	case bool:
+		return "boolean"
+	case time.Duration:
+		return "duration"
+	case string:
+		return "string"
+	case color.Color:
+		return "color"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func (e *env) eval(x expr) (value, error) {
+	switch x := x.(type) {
+	case *literal:
+		return x.val, nil
+
+	case *identExpr:
+		if v, ok := e.lookup(x.tok.text); ok {
+			if err, failed := v.(error); failed {
+				return nil, err
+			}
+			return v, nil
+		}
+		return nil, errorAt(x.tok, "undefined variable %s", x.tok.text)
+
+	case *unaryExpr:
+		v, err := e.eval(x.x)
+		if err != nil {
+			return nil, err
+		}
+		if x.op.text == "!" {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, errorAt(x.op, "operator ! requires a boolean, got %s", typeName(v))
+			}
+			return !b, nil
+		}
+		n, ok := number(v)
+		if !ok {
+			return nil, errorAt(x.op, "operator %s requires a number, got %s", x.op.text, typeName(v))
+		}
+		if x.op.text == "-" {
+			n = -n
+		}
+		return n, nil
+
+	case *binaryExpr:
+		return e.binary(x)
+
+	case *callExpr:
+		return e.call(x)
+	}
+	panic(fmt.Sprintf("unknown expression %T", x))
+}
+
+func (e *env) binary(x *binaryExpr) (value, error) {
+	a, err := e.eval(x.x)
+	if err != nil {
+		return nil, err
+	}
+	if op := x.op.text; op == "&&" || op == "||" {
+		return e.logical(x, a)
+	}
+	b, err := e.eval(x.y)
+	if err != nil {
+		return nil, err
+	}
+
+	if sa, ok := a.(string); ok && x.op.text == "+" {
+		if sb, ok := b.(string); ok {
+			// Рекурсивна процедура може подвоювати рядок на кожному виклику, тож довжина обмежується.
+			if n := e.x.p.maxString(); len(sa)+len(sb) > n {
+				return nil, e.x.limit(x.op, "string is longer than %d bytes", n)
+			}
+			return sa + sb, nil
+		}
+	}
+	if op := x.op.text; (op == "==" || op == "!=") && typeName(a) == typeName(b) {
+		eq := equal(a, b)
+		return eq == (op == "=="), nil
+	}
+	na, okA := number(a)
+	nb, okB := number(b)
+	if !okA || !okB {
+		return nil, errorAt(x.op, "operator %s is not defined for %s and %s", x.op.text, typeName(a), typeName(b))
+	}
+	switch x.op.text {
+	case "<":
+		return na < nb, nil
+	case "<=":
+		return na <= nb, nil
+	case ">":
+		return na > nb, nil
+	case ">=":
+		return na >= nb, nil
+	case "+":
+		return na + nb, nil
+	case "-":
+		return na - nb, nil
+	case "*":
+		return na * nb, nil
+	case "/":
+		if nb == 0 {
+			return nil, errorAt(x.op, "division by zero")
+		}
+		return na / nb, nil
+	case "%":
+		if nb == 0 {
+			return nil, errorAt(x.op, "division by zero")
+		}
+		return math.Mod(na, nb), nil
+	}
+	return nil, errorAt(x.op, "unknown operator %s", x.op.text)
+}
+
+// logical обчислює && та || за скороченою схемою: правий операнд обчислюється лише за потреби.
+func (e *env) logical(x *binaryExpr, a value) (value, error) {
+	ba, ok := a.(bool)
+	if !ok {
+		return nil, errorAt(x.op, "operator %s requires booleans, got %s", x.op.text, typeName(a))
+	}
+	if ba == (x.op.text == "||") {
+		return ba, nil
+	}
+	b, err := e.eval(x.y)
+	if err != nil {
+		return nil, err
+	}
+	bb, ok := b.(bool)
+	if !ok {
+		return nil, errorAt(x.op, "operator %s requires booleans, got %s", x.op.text, typeName(b))
+	}
+	return bb, nil
+}
+
+// equal порівнює значення одного типу. Кольори рівні, якщо збігаються їхні RGBA компоненти.
+func equal(a, b value) bool {
+	if ca, ok := a.(color.Color); ok {
+		cb := b.(color.Color)
+		r1, g1, b1, a1 := ca.RGBA()
+		r2, g2, b2, a2 := cb.RGBA()
+		return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+	}
+	if na, ok := number(a); ok {
+		nb, _ := number(b)
+		return na == nb
+	}
+	return a == b
+}
+
+// builtin — вбудована функція виразів.
+type builtin struct {
+	minArgs, maxArgs int // maxArgs < 0 означає довільну кількість
+	fn               func(args []value) (value, error)
+}
+
+func mathFunc(f func(float64) float64) builtin {
+	return builtin{1, 1, func(args []value) (value, error) {
+		n, ok := number(args[0])
+		if !ok {
+			return nil, fmt.Errorf("argument must be a number, got %s", typeName(args[0]))
+		}
+		return f(n), nil
+	}}
+}
+
+func numbers(args []value) ([]float64, error) {
+	ns := make([]float64, len(args))
+	for i, a := range args {
+		n, ok := number(a)
+		if !ok {
+			return nil, fmt.Errorf("argument %d must be a number, got %s", i+1, typeName(a))
+		}
+		ns[i] = n
+	}
+	return ns, nil
+}
+
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"sin":   mathFunc(math.Sin),
+		"cos":   mathFunc(math.Cos),
+		"tan":   mathFunc(math.Tan),
+		"asin":  mathFunc(math.Asin),
+		"acos":  mathFunc(math.Acos),
+		"atan":  mathFunc(math.Atan),
+		"sqrt":  mathFunc(math.Sqrt),
+		"abs":   mathFunc(math.Abs),
+		"floor": mathFunc(math.Floor),
+		"ceil":  mathFunc(math.Ceil),
+		"round": mathFunc(math.Round),
+		"atan2": {2, 2, func(args []value) (value, error) {
+			ns, err := numbers(args)
+			if err != nil {
+				return nil, err
+			}
+			return math.Atan2(ns[0], ns[1]), nil
+		}},
+		"pow": {2, 2, func(args []value) (value, error) {
+			ns, err := numbers(args)
+			if err != nil {
+				return nil, err
+			}
+			return math.Pow(ns[0], ns[1]), nil
+		}},
+		"min": {1, -1, func(args []value) (value, error) {
+			ns, err := numbers(args)
+			if err != nil {
+				return nil, err
+			}
+			m := ns[0]
+			for _, n := range ns[1:] {
+				m = math.Min(m, n)
+			}
+			return m, nil
+		}},
+		"max": {1, -1, func(args []value) (value, error) {
+			ns, err := numbers(args)
+			if err != nil {
+				return nil, err
+			}
+			m := ns[0]
+			for _, n := range ns[1:] {
+				m = math.Max(m, n)
+			}
+			return m, nil
+		}},
+		"rgb":  {3, 3, rgbaFunc},
+		"rgba": {4, 4, rgbaFunc},
+	}
+}
+
+// rgbaFunc будує колір з каналів 0..255 (або відсотків) та необов'язкової прозорості 0..1 (або відсотків).
+func rgbaFunc(args []value) (value, error) {
+	ch := [4]uint8{3: 255}
+	for i, a := range args {
+		limit := 255.0
+		if i == 3 {
+			limit = 1
+		}
+		var v float64
+		switch a := a.(type) {
+		case percent:
+			v = float64(a) / 100 * limit
+		case float64:
+			v = a
+		default:
+			return nil, fmt.Errorf("component %d must be a number, got %s", i+1, typeName(a))
+		}
+		if v < 0 || v > limit {
+			return nil, fmt.Errorf("component %d must be in [0, %g], got %g", i+1, limit, v)
+		}
+		ch[i] = uint8(v*255/limit + 0.5)
+	}
+	return color.NRGBA{R: ch[0], G: ch[1], B: ch[2], A: ch[3]}, nil
+}
+
+func (e *env) call(x *callExpr) (value, error) {
+	name := x.name.text
+	b, ok := builtins[name]
+	if !ok {
+		return nil, errorAt(x.name, "unknown function %s", name)
+	}
+	if len(x.args) < b.minArgs || b.maxArgs >= 0 && len(x.args) > b.maxArgs {
+		want := arityString(b.minArgs, b.maxArgs)
+		if b.maxArgs < 0 {
+			want = fmt.Sprintf("at least %d arguments", b.minArgs)
+		}
+		return nil, errorAt(x.name, "function %s expects %s, got %d", name, want, len(x.args))
+	}
+	args := make([]value, len(x.args))
+	for i, a := range x.args {
+		v, err := e.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := b.fn(args)
+	if err != nil {
+		return nil, errorAt(x.name, "%s: %v", name, err)
+	}
+	return v, nil
+}
+
+// evalNumber обчислює вираз, який має бути числом.
+func (e *env) evalNumber(x expr) (float64, error) {
+	v, err := e.eval(x)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := number(v)
+	if !ok {
+		return 0, errorAt(x.pos(), "expected a number, got %s", typeName(v))
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, errorAt(x.pos(), "expression is not a finite number")
+	}
+	return n, nil
+}
+
+// evalColor обчислює вираз, який має бути кольором. Ідентифікатор, який не є змінною, вважається назвою кольору.
+func (e *env) evalColor(x expr) (color.Color, error) {
+	if id, ok := x.(*identExpr); ok {
+		if _, defined := e.lookup(id.tok.text); !defined {
+			c, err := parseColor(id.tok.text)
+			if err != nil {
+				return nil, errorAt(id.tok, "%v", err)
+			}
+			return c, nil
+		}
+	}
+	v, err := e.eval(x)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case color.Color:
+		return v, nil
+	case string:
+		c, err := parseColor(v)
+		if err != nil {
+			return nil, errorAt(x.pos(), "%v", err)
+		}
+		return c, nil
+	}
+	return nil, errorAt(x.pos(), "expected a color, got %s", typeName(v))
+}
+
+// evalBool обчислює умову.
+func (e *env) evalBool(x expr) (bool, error) {
+	v, err := e.eval(x)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, errorAt(x.pos(), "condition must be a boolean, got %s", typeName(v))
+	}
+	return b, nil
+}
+
+// evalArg обчислює аргумент процедури. Як і в evalColor, ідентифікатор, який не є змінною, стає рядком, щоб
+// назви кольорів можна було передавати без лапок.
+func (e *env) evalArg(x expr) (value, error) {
+	if id, ok := x.(*identExpr); ok {
+		if _, defined := e.lookup(id.tok.text); !defined {
+			return id.tok.text, nil
+		}
+	}
+	return e.eval(x)
+}
+
+// evalText обчислює текст. Числа записуються в найкоротшому вигляді, тож "text x y 0.05 i" підписує значення i.
+func (e *env) evalText(x expr) (string, error) {
+	v, err := e.evalArg(x)
+	if err != nil {
+		return "", err
+	}
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return "", errorAt(x.pos(), "expected text, got %s", typeName(v))
+	}
+	if s == "" {
+		return "", errorAt(x.pos(), "text must not be empty")
+	}
+	return s, nil
+}
+
+// evalValue обчислює число або колір. Як і в evalColor, ідентифікатор, який не є змінною, та рядок вважаються
+// кольором.
+func (e *env) evalValue(x expr) (any, error) {
+	v, err := e.evalArg(x)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := number(v); ok {
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, errorAt(x.pos(), "expression is not a finite number")
+		}
+		return n, nil
+	}
+	switch v := v.(type) {
+	case color.Color:
+		return v, nil
+	case string:
+		c, err := parseColor(v)
+		if err != nil {
+			return nil, errorAt(x.pos(), "%v", err)
+		}
+		return c, nil
+	}
+	return nil, errorAt(x.pos(), "expected a number or a color, got %s", typeName(v))
+}
+
+// evalDuration обчислює тривалість. Число без одиниці означає секунди.
+func (e *env) evalDuration(x expr) (time.Duration, error) {
+	v, err := e.eval(x)
+	if err != nil {
+		return 0, err
+	}
+	d, ok := v.(time.Duration)
+	if !ok {
+		n, isNum := number(v)
+		if !isNum {
+			return 0, errorAt(x.pos(), "expected a duration, got %s", typeName(v))
+		}
+		if math.IsNaN(n) || n*float64(time.Second) > math.MaxInt64 {
+			return 0, errorAt(x.pos(), "duration is out of range")
+		}
+		d = time.Duration(n * float64(time.Second))
+	}
+	if d < 0 {
+		return 0, errorAt(x.pos(), "duration must not be negative")
+	}
+	return d, nil
+}
+
+// evalCount обчислює кількість — ціле число, не менше за 1.
+func (e *env) evalCount(x expr) (int, error) {
+	n, err := e.evalNumber(x)
+	if err != nil {
+		return 0, err
+	}
+	if n != math.Trunc(n) || n < 1 || n > math.MaxInt32 {
+		return 0, errorAt(x.pos(), "expected a positive integer, got %g", n)
+	}
+	return int(n), nil
+}
+
+// evalName обчислює назву. Як і в evalArg, ідентифікатор, який не є змінною, означає сам себе.
+func (e *env) evalName(x expr) (string, error) {
+	v, err := e.evalArg(x)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errorAt(x.pos(), "expected a name, got %s", typeName(v))
+	}
+	if s == "" {
+		return "", errorAt(x.pos(), "name must not be empty")
+	}
+	return s, nil
+}
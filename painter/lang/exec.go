@@ -0,0 +1,234 @@
+package lang
+
+import (
+	"errors"
+	"maps"
+	"math"
+
+	"github.com/DmytroHalai/kpi-3/painter"
+)
+
+// Типові обмеження виконання скрипта, див. Parser.
+const (
+	DefaultMaxOps    = 100_000
+	DefaultMaxSteps  = 1_000_000
+	DefaultMaxDepth  = 64
+	DefaultMaxString = 64 << 10
+	DefaultMaxBytes  = 32 << 20
+)
+
+// errLimit зупиняє виконання скрипта після перевищення обмеження.
+var errLimit = errors.New("script limit exceeded")
+
+// executor розгортає інструкції скрипта у список операцій.
+type executor struct {
+	p     *Parser
+	procs map[string]*procedure
+	ops   []painter.Operation
+	errs  ParseErrors
+	seen  map[ParseError]bool
+	steps int
+	depth int
+	bytes int // обсяг даних операцій, див. cmdArgs.size
+}
+
+// procedure — процедура, оголошена через def, разом з областю видимості, в якій її оголошено.
+type procedure struct {
+	def   *defStmt
+	scope *env
+}
+
+func newExecutor(p *Parser) *executor {
+	return &executor{p: p, procs: map[string]*procedure{}, seen: map[ParseError]bool{}}
+}
+
+// report додає помилку, якщо така сама ще не повідомлялась: помилка в тілі циклу інакше повторювалась би на
+// кожній ітерації.
+func (x *executor) report(err error) {
+	var pe *ParseError
+	if !errors.As(err, &pe) || x.seen[*pe] {
+		return
+	}
+	x.seen[*pe] = true
+	x.errs = append(x.errs, pe)
+}
+
+// evalError повідомляє про помилку обчислення виразу. Перевищення обмеження повертається, щоб зупинити виконання.
+func (x *executor) evalError(err error) error {
+	if errors.Is(err, errLimit) {
+		return err
+	}
+	x.report(err)
+	return nil
+}
+
+// limit повідомляє про перевищення обмеження й повертає errLimit.
+func (x *executor) limit(t token, format string, args ...any) error {
+	x.report(errorAt(t, format, args...))
+	return errLimit
+}
+
+func (x *executor) globals() *env {
+	e := newEnv(nil)
+	e.x = x
+	maps.Copy(e.vars, constants)
+	return newEnv(e)
+}
+
+// run виконує інструкції в області видимості scope. Помилка повертається лише тоді, коли виконання треба зупинити
+// повністю; решта помилок накопичуються в x.errs.
+func (x *executor) run(scope *env, stmts []stmt) error {
+	for _, s := range stmts {
+		x.steps++
+		if x.steps > x.p.maxSteps() {
+			return x.limit(s.pos(), "script exceeds %d steps", x.p.maxSteps())
+		}
+		if err := x.exec(scope, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *executor) exec(scope *env, s stmt) error {
+	switch s := s.(type) {
+	case *letStmt:
+		v, err := scope.eval(s.value)
+		if err != nil {
+			if err := x.evalError(err); err != nil {
+				return err
+			}
+			v = errReported
+		}
+		scope.vars[s.name.text] = v
+
+	case *cmdStmt:
+		if proc, ok := x.procs[s.name.text]; ok {
+			return x.call(scope, proc, s)
+		}
+		op, size, errs := x.p.parseCommand(scope, s)
+		for _, err := range errs {
+			x.report(err)
+		}
+		if op != nil {
+			x.ops = append(x.ops, op)
+			if len(x.ops) > x.p.maxOps() {
+				return x.limit(s.name, "script produces more than %d operations", x.p.maxOps())
+			}
+			// Кількість операцій не обмежує пам'ять: одна команда може містити тисячі точок.
+			x.bytes += size
+			if x.bytes > x.p.maxBytes() {
+				return x.limit(s.name, "script operations hold more than %d bytes of data", x.p.maxBytes())
+			}
+		}
+
+	case *repeatStmt:
+		n, err := scope.evalNumber(s.count)
+		if err != nil {
+			return x.evalError(err)
+		}
+		if math.IsNaN(n) || n < 0 {
+			x.report(errorAt(s.count.pos(), "repeat count must not be negative, got %g", n))
+			return nil
+		}
+		// Кожна ітерація — крок, тож більшої кількості не виконати, а int(n) для величезного n переповнюється.
+		if n > float64(x.p.maxSteps()) {
+			return x.limit(s.count.pos(), "repeat count %g exceeds %d steps", n, x.p.maxSteps())
+		}
+		for i := 0; i < int(n); i++ {
+			if err := x.iterate(s.kw, newEnv(scope), s.body); err != nil {
+				return err
+			}
+		}
+
+	case *forStmt:
+		from, err := scope.evalNumber(s.from)
+		if err != nil {
+			return x.evalError(err)
+		}
+		to, err := scope.evalNumber(s.to)
+		if err != nil {
+			return x.evalError(err)
+		}
+		for i := from; i < to; i++ {
+			body := newEnv(scope)
+			body.vars[s.name.text] = i
+			if err := x.iterate(s.kw, body, s.body); err != nil {
+				return err
+			}
+		}
+
+	case *defStmt:
+		name := s.name.text
+		if _, ok := commands[name]; ok {
+			x.report(errorAt(s.name, "%s is a command and cannot be redefined", name))
+			return nil
+		}
+		if _, ok := builtins[name]; ok {
+			x.report(errorAt(s.name, "%s is a function and cannot be redefined", name))
+			return nil
+		}
+		x.procs[name] = &procedure{def: s, scope: scope}
+
+	case *ifStmt:
+		ok, err := scope.evalBool(s.cond)
+		if err != nil {
+			return x.evalError(err)
+		}
+		body := s.els
+		if ok {
+			body = s.then
+		}
+		return x.run(newEnv(scope), body)
+	}
+	return nil
+}
+
+// iterate виконує одну ітерацію циклу. Ітерація рахується як крок, тому порожній цикл теж обмежений.
+func (x *executor) iterate(kw token, scope *env, body []stmt) error {
+	x.steps++
+	if x.steps > x.p.maxSteps() {
+		return x.limit(kw, "script exceeds %d steps", x.p.maxSteps())
+	}
+	return x.run(scope, body)
+}
+
+func (x *executor) call(scope *env, proc *procedure, s *cmdStmt) error {
+	d := proc.def
+	if len(s.args) != len(d.params) {
+		x.report(errorAt(s.name, "procedure %s expects %s, got %d", d.name.text, arityString(len(d.params), len(d.params)), len(s.args)))
+		return nil
+	}
+	body := newEnv(proc.scope)
+	for i, arg := range s.args {
+		if arg.key != "" {
+			x.report(errorAt(arg.tok, "procedure %s has no options", d.name.text))
+			return nil
+		}
+		v, err := scope.evalArg(arg.value)
+		if err != nil {
+			return x.evalError(err)
+		}
+		body.vars[d.params[i].text] = v
+	}
+
+	x.depth++
+	defer func() { x.depth-- }()
+	if x.depth > x.p.maxDepth() {
+		return x.limit(s.name, "procedure calls are nested deeper than %d levels", x.p.maxDepth())
+	}
+	return x.run(body, d.body)
+}
+
+func (p *Parser) maxOps() int    { return orDefault(p.MaxOps, DefaultMaxOps) }
+func (p *Parser) maxSteps() int  { return orDefault(p.MaxSteps, DefaultMaxSteps) }
+func (p *Parser) maxDepth() int  { return orDefault(p.MaxDepth, DefaultMaxDepth) }
+func (p *Parser) maxString() int { return orDefault(p.MaxString, DefaultMaxString) }
+func (p *Parser) maxBytes() int  { return orDefault(p.MaxBytes, DefaultMaxBytes) }
+
+func orDefault(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -1,31 +1,391 @@
 package lang
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/DmytroHalai/kpi-3/painter"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
 )
 
+// maxRequestSize обмежує розмір тіла запиту до HttpHandler та JSONHandler.
+const maxRequestSize = 1 << 20
+
 // HttpHandler конструює обробник HTTP запитів, який дані з запиту віддає у Parser, а потім відправляє отриманий список
-// операцій у painter.Loop.
-func HttpHandler(loop *painter.Loop, p *Parser, scene *painter.Scene) http.Handler {
+// операцій у painter.Loop. Помилки скрипта повертаються у тілі відповіді: текстом або, якщо клієнт приймає
+// application/json, як {"errors": [...]} зі списком ParseError. Відповідь надсилається, коли записано файли команд
+// save, а якщо запис чи читання файлу сцени не вдалися, повертається помилка.
+func HttpHandler(loop *painter.Loop, p *Parser) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		var in io.Reader = r.Body
+		var in io.Reader = http.MaxBytesReader(rw, r.Body, maxRequestSize)
 		if r.Method == http.MethodGet {
 			in = strings.NewReader(r.URL.Query().Get("cmd"))
 		}
 
-		cmds, err := p.Parse(in, scene)
+		cmds, err := p.Parse(in)
 		if err != nil {
 			log.Printf("Bad script: %s", err)
-			rw.WriteHeader(http.StatusBadRequest)
+			writeParseError(rw, r, err)
 			return
 		}
 
-		loop.Post(painter.OperationList(cmds))
+		if err := post(r.Context(), loop, cmds); err != nil {
+			http.Error(rw, err.Error(), postStatus(err))
+			return
+		}
 		rw.WriteHeader(http.StatusOK)
 	})
 }
+
+// errSave позначає помилку запису файлу сцени: її не спричинив запит, навіть якщо каталогу сцен не існує.
+var errSave = errors.New("cannot save scene")
+
+// post відправляє операції запиту в цикл одним списком і одним кроком історії сцени, тож інші запити не
+// вклинюються між ними, а весь запит скасовується одним undo. Файли сцен обробляються поза циклом подій: файли команд
+// load читаються до відправлення, після запису файлів попередніх запитів, а потім post чекає, доки буде записано файли
+// команд save. Якщо файл прочитати не вдалося, жодна операція запиту не виконується. Файл, який записує save цього ж
+// запиту, не читається з диска: load відновлює сцену з того самого знімка, що потрапляє у файл.
+func post(ctx context.Context, loop *painter.Loop, ops []painter.Operation) error {
+	loads := make(map[string]bool)
+	for _, op := range ops {
+		if op, ok := op.(loadScene); ok {
+			loads[op.Path] = true
+		}
+	}
+	if len(loads) > 0 {
+		painter.WaitSaves()
+	}
+
+	var (
+		list  = painter.OperationList{painter.Record{}}
+		saves []<-chan error
+		saved = make(map[string]*bytes.Buffer)
+	)
+	for _, op := range ops {
+		switch op := op.(type) {
+		case painter.Save:
+			done := make(chan error, 1)
+			op.Done = done
+			saves = append(saves, done)
+			list = append(list, op)
+			if loads[op.Path] {
+				saved[op.Path] = new(bytes.Buffer)
+				list = append(list, encodeScene{saved[op.Path]})
+			}
+		case loadScene:
+			if data := saved[op.Path]; data != nil {
+				list = append(list, decodeScene{data})
+				continue
+			}
+			scene, err := painter.LoadScene(op.Path)
+			if err != nil {
+				return fmt.Errorf("cannot load scene: %w", err)
+			}
+			list = append(list, painter.ReplaceScene{Scene: scene})
+		default:
+			list = append(list, op)
+		}
+	}
+	if err := loop.Post(list); err != nil {
+		return err
+	}
+	for _, done := range saves {
+		select {
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("%w: %w", errSave, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// loadScene — операція команди load. Перед відправленням у цикл post замінює її на painter.ReplaceScene зі сценою з
+// файлу Path, тож сама операція нічого не робить.
+type loadScene struct {
+	Path string
+}
+
+func (op loadScene) Do(t screen.Texture, s *painter.Scene) bool {
+	return false
+}
+
+// encodeScene записує сцену в буфер так само, як її записує у файл painter.Save.
+type encodeScene struct {
+	data *bytes.Buffer
+}
+
+func (op encodeScene) Do(t screen.Texture, s *painter.Scene) bool {
+	op.data.Reset()
+	if err := painter.EncodeScene(op.data, s); err != nil {
+		log.Printf("Cannot encode scene: %s", err)
+	}
+	return false
+}
+
+// decodeScene замінює сцену вмістом буфера, записаного encodeScene.
+type decodeScene struct {
+	data *bytes.Buffer
+}
+
+func (op decodeScene) Do(t screen.Texture, s *painter.Scene) bool {
+	scene, err := painter.DecodeScene(bytes.NewReader(op.data.Bytes()))
+	if err != nil {
+		log.Printf("Cannot load scene: %s", err)
+		return false
+	}
+	return painter.ReplaceScene{Scene: scene}.Do(t, s)
+}
+
+// postStatus повертає статус HTTP відповіді для помилки post.
+func postStatus(err error) int {
+	switch {
+	case errors.Is(err, painter.ErrStopped):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errSave):
+		return http.StatusInternalServerError
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeParseError(rw http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		var errs ParseErrors
+		if !errors.As(err, &errs) {
+			errs = ParseErrors{{Message: err.Error()}}
+		}
+		writeJSON(rw, http.StatusBadRequest, map[string]any{"errors": errs})
+		return
+	}
+	http.Error(rw, err.Error(), http.StatusBadRequest)
+}
+
+// JSONHandler конструює обробник HTTP запитів, який розбирає JSON запит через Parser.ParseJSON та відправляє отримані
+// операції у painter.Loop. Помилки повертаються у тілі відповіді як {"errors": [...]} зі списком OpError. Файли
+// сцен записуються та читаються так само, як в HttpHandler.
+func JSONHandler(loop *painter.Loop, p *Parser) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", "POST")
+			writeJSON(rw, http.StatusMethodNotAllowed, map[string]any{
+				"errors": OpErrors{{Index: -1, Message: "method not allowed"}},
+			})
+			return
+		}
+
+		cmds, err := p.ParseJSON(http.MaxBytesReader(rw, r.Body, maxRequestSize))
+		if err != nil {
+			writeJSON(rw, http.StatusBadRequest, map[string]any{"errors": err})
+			return
+		}
+		if err := post(r.Context(), loop, cmds); err != nil {
+			writeJSON(rw, postStatus(err), map[string]any{
+				"errors": OpErrors{{Index: -1, Message: err.Error()}},
+			})
+			return
+		}
+		writeJSON(rw, http.StatusOK, map[string]any{"accepted": len(cmds)})
+	})
+}
+
+// SchemaHandler віддає JSON Schema запитів, які приймає JSONHandler.
+func SchemaHandler() http.Handler {
+	schema := JSONSchema()
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/schema+json")
+		_, _ = rw.Write(schema)
+	})
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Printf("Cannot write response: %s", err)
+	}
+}
+
+const (
+	maxAssetSize   = 8 << 20  // найбільший розмір файлу ресурсу
+	maxAssetPixels = 16 << 20 // найбільша кількість пікселів розпакованого ресурсу
+)
+
+// AssetHandler конструює обробник HTTP запитів до реєстру ресурсів. POST або PUT з параметром name зберігає PNG чи
+// JPEG з тіла запиту під цією назвою і перемальовує сцену в painter.Loop, DELETE видаляє ресурс, а GET повертає
+// список назв ресурсів. Якщо реєстр заповнено, новий ресурс відхиляється зі статусом 507.
+func AssetHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			writeJSON(rw, http.StatusOK, map[string]any{"assets": painter.AssetNames()})
+			return
+		case http.MethodDelete:
+			if !painter.DeleteAsset(r.URL.Query().Get("name")) {
+				http.Error(rw, "asset not found", http.StatusNotFound)
+				return
+			}
+			if err := loop.Post(painter.Redraw{}); err != nil {
+				http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodPost, http.MethodPut:
+		default:
+			rw.Header().Set("Allow", "GET, HEAD, POST, PUT, DELETE")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxAssetSize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(rw, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Розміри перевіряються за заголовком файлу, щоб не розпаковувати завеликі зображення.
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || format != "png" && format != "jpeg" {
+			http.Error(rw, "asset must be a PNG or JPEG image", http.StatusUnsupportedMediaType)
+			return
+		}
+		if cfg.Width*cfg.Height > maxAssetPixels {
+			http.Error(rw, fmt.Sprintf("asset is %dx%d, at most %d pixels are allowed", cfg.Width, cfg.Height, maxAssetPixels),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			http.Error(rw, "cannot decode asset: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := r.URL.Query().Get("name")
+		if err := painter.StoreAsset(name, img); err != nil {
+			code := http.StatusBadRequest
+			if errors.Is(err, painter.ErrAssetsFull) {
+				code = http.StatusInsufficientStorage
+			}
+			http.Error(rw, err.Error(), code)
+			return
+		}
+		if err := loop.Post(painter.Redraw{}); err != nil {
+			http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		b := img.Bounds()
+		writeJSON(rw, http.StatusOK, map[string]any{"name": name, "format": format, "width": b.Dx(), "height": b.Dy()})
+	})
+}
+
+// FrameSource надає копію останнього кадру, відправленого у painter.Receiver.
+type FrameSource interface {
+	Frame() *image.RGBA
+}
+
+const maxSnapshotScale = 8
+
+// SnapshotHandler конструює обробник HTTP запитів, який повертає останній кадр у форматі PNG. Параметр format=jpeg
+// змінює формат на JPEG (якість задається через quality), а scale змінює розмір зображення.
+func SnapshotHandler(src FrameSource) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			rw.Header().Set("Allow", "GET, HEAD")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		scale := 1.0
+		if s := q.Get("scale"); s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || v <= 0 || v > maxSnapshotScale {
+				http.Error(rw, fmt.Sprintf("scale must be a number in (0, %d]", maxSnapshotScale), http.StatusBadRequest)
+				return
+			}
+			scale = v
+		}
+		quality := jpeg.DefaultQuality
+		if s := q.Get("quality"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 1 || v > 100 {
+				http.Error(rw, "quality must be an integer in [1, 100]", http.StatusBadRequest)
+				return
+			}
+			quality = v
+		}
+		format := strings.ToLower(q.Get("format"))
+		switch format {
+		case "", "png":
+			format = "png"
+		case "jpg", "jpeg":
+			format = "jpeg"
+		default:
+			http.Error(rw, "unsupported format: "+format, http.StatusBadRequest)
+			return
+		}
+
+		frame := src.Frame()
+		if frame == nil {
+			http.Error(rw, "no frame has been rendered yet", http.StatusServiceUnavailable)
+			return
+		}
+		var img image.Image = frame
+		if scale != 1 {
+			img = scaleImage(frame, scale)
+		}
+
+		var buf bytes.Buffer
+		var err error
+		if format == "png" {
+			err = png.Encode(&buf, img)
+		} else {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		}
+		if err != nil {
+			log.Printf("Cannot encode snapshot: %s", err)
+			http.Error(rw, "cannot encode snapshot", http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "image/"+format)
+		rw.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		rw.Header().Set("Cache-Control", "no-store")
+		if r.Method == http.MethodGet {
+			_, _ = buf.WriteTo(rw)
+		}
+	})
+}
+
+func scaleImage(src *image.RGBA, scale float64) *image.RGBA {
+	w := max(1, int(float64(src.Rect.Dx())*scale+0.5))
+	h := max(1, int(float64(src.Rect.Dy())*scale+0.5))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(dst, dst.Rect, src, src.Rect, draw.Src, nil)
+	return dst
+}
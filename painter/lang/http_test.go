@@ -0,0 +1,211 @@
+package lang
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DmytroHalai/kpi-3/painter"
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+)
+
+type frameSourceFunc func() *image.RGBA
+
+func (f frameSourceFunc) Frame() *image.RGBA { return f() }
+
+func TestSnapshotHandler_PNG(t *testing.T) {
+	frame := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	frame.Set(0, 0, color.White)
+	h := SnapshotHandler(frameSourceFunc(func() *image.RGBA { return frame }))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snapshot?scale=0.5", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("response is not a PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(20, 10) {
+		t.Errorf("expected scaled size 20x10, got %v", size)
+	}
+}
+
+func TestSnapshotHandler_Errors(t *testing.T) {
+	empty := SnapshotHandler(frameSourceFunc(func() *image.RGBA { return nil }))
+	rec := httptest.NewRecorder()
+	empty.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snapshot", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a frame, got %d", rec.Code)
+	}
+
+	h := SnapshotHandler(frameSourceFunc(func() *image.RGBA { return image.NewRGBA(image.Rect(0, 0, 1, 1)) }))
+	for _, target := range []string{"/snapshot?format=gif", "/snapshot?scale=0", "/snapshot?format=jpeg&quality=0"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestHttpHandler_ErrorBody(t *testing.T) {
+	h := HttpHandler(nil, &Parser{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("white\nfigure 1")))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "line 2, column 1: figure:") {
+		t.Errorf("unexpected text response %d: %q", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("jump\nfigure 1 x"))
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(rec, req)
+	var body struct {
+		Errors []ParseError `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if len(body.Errors) != 2 || body.Errors[1].Line != 2 || body.Errors[1].Column != 10 {
+		t.Errorf("unexpected errors: %+v", body.Errors)
+	}
+}
+
+func TestHttpHandler_SaveLoad(t *testing.T) {
+	var (
+		loop   painter.Loop
+		frames headless.Recorder
+	)
+	loop.Receiver = &frames
+	loop.Start(headless.Screen{})
+	defer loop.StopAndWait()
+	dir := t.TempDir()
+	h := HttpHandler(&loop, &Parser{SceneDir: dir})
+	send := func(h http.Handler, script string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(script)))
+		return rec
+	}
+
+	// Відповідь надсилається вже після запису файлу.
+	if rec := send(h, "figure id=a 0.5 0.5\nsave one"); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "one.json")); err != nil {
+		t.Fatalf("scene file was not written: %v", err)
+	}
+	if rec := send(h, "reset\nfigure id=b 0.1 0.1\nsave two\nload one\nsave three"); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	for name, id := range map[string]string{"two": "b", "three": "a"} {
+		s, err := painter.LoadScene(filepath.Join(dir, name+".json"))
+		if err != nil || len(s.Shapes) != 1 || s.Shapes[0].ID != id {
+			t.Errorf("%s: expected shape %q, got %+v, %v", name, id, s.Shapes, err)
+		}
+	}
+
+	// Файл, записаний у тому ж запиті, читається вже з новою сценою.
+	if rec := send(h, "figure id=c 0.3 0.3\nsave four\nreset\nload four\nsave five"); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	if s, err := painter.LoadScene(filepath.Join(dir, "five.json")); err != nil || len(s.Shapes) != 2 {
+		t.Errorf("five: expected shapes a and c, got %+v, %v", s.Shapes, err)
+	}
+	if s := loop.Scene(); len(s.Shapes) != 2 {
+		t.Errorf("scene was not loaded from the same request: %+v", s.Shapes)
+	}
+	send(h, "undo")
+
+	if rec := send(h, "figure id=d 0.2 0.2\nload missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing file: unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	broken := HttpHandler(&loop, &Parser{SceneDir: filepath.Join(dir, "missing")})
+	if rec := send(broken, "save one"); rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "cannot save") {
+		t.Errorf("failed save: unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	if s := loop.Scene(); len(s.Shapes) != 1 || s.Shapes[0].ID != "a" {
+		t.Errorf("operations after a failed load must not run: %+v", s.Shapes)
+	}
+}
+
+func TestAssetHandler(t *testing.T) {
+	var loop painter.Loop
+	h := AssetHandler(&loop)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/assets?name=icon", bytes.NewReader(buf.Bytes())))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"width":3`) {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	if a := painter.Asset("icon"); a == nil || a.Rect.Size() != image.Pt(3, 2) {
+		t.Errorf("asset was not stored: %v", a)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets", nil))
+	if !strings.Contains(rec.Body.String(), `"icon"`) {
+		t.Errorf("asset list does not contain the new asset: %s", rec.Body)
+	}
+
+	for _, tc := range []struct {
+		target string
+		body   []byte
+		code   int
+	}{
+		{"/assets?name=../icon", buf.Bytes(), http.StatusBadRequest},
+		{"/assets?name=text", []byte("not an image"), http.StatusUnsupportedMediaType},
+		{"/assets?name=big", make([]byte, maxAssetSize+1), http.StatusRequestEntityTooLarge},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, tc.target, bytes.NewReader(tc.body)))
+		if rec.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d: %s", tc.target, tc.code, rec.Code, rec.Body)
+		}
+	}
+
+	// Коли реєстр заповнено, нові ресурси відхиляються, а наявні можна замінити або видалити.
+	for i := len(painter.AssetNames()); i < painter.MaxAssets; i++ {
+		name := "fill" + strconv.Itoa(i)
+		if err := painter.StoreAsset(name, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { painter.DeleteAsset(name) })
+	}
+	for _, tc := range []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodPost, "/assets?name=extra", http.StatusInsufficientStorage},
+		{http.MethodPost, "/assets?name=icon", http.StatusOK},
+		{http.MethodDelete, "/assets?name=icon", http.StatusNoContent},
+		{http.MethodDelete, "/assets?name=icon", http.StatusNotFound},
+		{http.MethodPost, "/assets?name=extra", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, bytes.NewReader(buf.Bytes())))
+		if rec.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d: %s", tc.method, tc.target, tc.code, rec.Code, rec.Body)
+		}
+	}
+	painter.DeleteAsset("extra")
+}
@@ -0,0 +1,366 @@
+package lang
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DmytroHalai/kpi-3/painter"
+)
+
+// OpError описує помилку в одній з операцій JSON запиту. Index дорівнює -1, якщо помилка стосується запиту цілком.
+type OpError struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e OpError) Error() string {
+	var b strings.Builder
+	if e.Index >= 0 {
+		fmt.Fprintf(&b, "ops[%d]", e.Index)
+		if e.Field != "" {
+			fmt.Fprintf(&b, ".%s", e.Field)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// OpErrors — усі помилки, знайдені у JSON запиті.
+type OpErrors []OpError
+
+func (es OpErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ParseJSON розбирає команди у форматі {"ops": [{"op": "figure", "x": 0.5, "y": 0.5}, ...]}. Кожна операція містить
+// назву команди в полі op, а її аргументи та опції — в полях з тими ж назвами, що й у JSONSchema. Будуються ті самі
+// операції, що й у Parse. Помилка завжди має тип OpErrors.
+func (p *Parser) ParseJSON(in io.Reader) ([]painter.Operation, error) {
+	var req struct {
+		Ops []map[string]json.RawMessage `json:"ops"`
+	}
+	dec := json.NewDecoder(in)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, OpErrors{{Index: -1, Message: "invalid request: " + err.Error()}}
+	}
+	if dec.More() {
+		return nil, OpErrors{{Index: -1, Message: "invalid request: unexpected data after the request object"}}
+	}
+	if req.Ops == nil {
+		return nil, OpErrors{{Index: -1, Field: "ops", Message: "field ops is required"}}
+	}
+
+	var (
+		res  []painter.Operation
+		errs OpErrors
+	)
+	for i, fields := range req.Ops {
+		op, opErrs := p.parseJSONOp(i, fields)
+		errs = append(errs, opErrs...)
+		if op != nil {
+			res = append(res, op)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return res, nil
+}
+
+func (p *Parser) parseJSONOp(index int, fields map[string]json.RawMessage) (painter.Operation, OpErrors) {
+	var name string
+	if raw, ok := fields["op"]; !ok {
+		return nil, OpErrors{{Index: index, Field: "op", Message: "field op is required"}}
+	} else if err := json.Unmarshal(raw, &name); err != nil {
+		return nil, OpErrors{{Index: index, Field: "op", Message: "op must be a string"}}
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return nil, OpErrors{{Index: index, Op: name, Field: "op", Message: "unknown command: " + name}}
+	}
+
+	var errs OpErrors
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, OpError{Index: index, Op: name, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	a := newCmdArgs(p)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "op" {
+			continue
+		}
+		raw := fields[key]
+		if prm, ok := cmd.param(key); ok {
+			switch prm.kind {
+			case coordArg:
+				var v float64
+				if err := json.Unmarshal(raw, &v); err != nil {
+					fail(key, "%s must be a number", key)
+					continue
+				}
+				a.coords[key] = v
+			case colorArg:
+				var s string
+				if err := json.Unmarshal(raw, &s); err != nil {
+					fail(key, "%s must be a color string", key)
+					continue
+				}
+				c, err := parseColor(s)
+				if err != nil {
+					fail(key, "%v", err)
+					continue
+				}
+				a.colors[key] = c
+			case durationArg:
+				d, err := parseJSONDuration(raw)
+				if err != nil {
+					fail(key, "%v", err)
+					continue
+				}
+				a.durations[key] = d
+			case countArg:
+				var n int
+				if err := json.Unmarshal(raw, &n); err != nil || n < 1 {
+					fail(key, "%s must be a positive integer", key)
+					continue
+				}
+				a.counts[key] = n
+			case nameArg:
+				var s string
+				if err := json.Unmarshal(raw, &s); err != nil || s == "" {
+					fail(key, "%s must be a non-empty string", key)
+					continue
+				}
+				a.names[key] = s
+			case pointsArg:
+				var v []float64
+				if err := json.Unmarshal(raw, &v); err != nil || len(v)%2 != 0 || len(v) < 2*prm.min {
+					fail(key, "%s must be an array of at least %d x y pairs", key, prm.min)
+					continue
+				}
+				a.pointSets[key] = v
+			case textArg:
+				var s string
+				if err := json.Unmarshal(raw, &s); err != nil || s == "" {
+					fail(key, "%s must be a non-empty string", key)
+					continue
+				}
+				a.texts[key] = s
+			case valuesArg:
+				var items []any
+				if err := json.Unmarshal(raw, &items); err != nil || len(items) < prm.min {
+					fail(key, "%s must be an array of at least %d numbers and color strings", key, prm.min)
+					continue
+				}
+				for _, item := range items {
+					switch v := item.(type) {
+					case float64:
+						a.values[key] = append(a.values[key], v)
+					case string:
+						c, err := parseColor(v)
+						if err != nil {
+							fail(key, "%v", err)
+							continue
+						}
+						a.values[key] = append(a.values[key], c)
+					default:
+						fail(key, "%s must contain only numbers and color strings", key)
+					}
+				}
+			}
+			continue
+		}
+		if _, ok := cmd.options[key]; !ok {
+			fail(key, "%s command has no field %s", name, key)
+			continue
+		}
+		if cmd.optKind(key) == coordArg {
+			var v float64
+			if err := json.Unmarshal(raw, &v); err != nil {
+				fail(key, "%s must be a number", key)
+				continue
+			}
+			if err := cmd.setNumberOption(a, key, v); err != nil {
+				fail(key, "%v", err)
+			}
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			fail(key, "%s must be a string", key)
+			continue
+		}
+		if err := cmd.setOption(a, key, s); err != nil {
+			fail(key, "%v", err)
+		}
+	}
+	for _, prm := range cmd.params {
+		if _, ok := fields[prm.name]; !ok && !prm.optional {
+			fail(prm.name, "field %s is required", prm.name)
+		}
+	}
+	for _, key := range cmd.require {
+		if _, ok := fields[key]; !ok {
+			fail(key, "field %s is required", key)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	op, err := cmd.build(a)
+	if err != nil {
+		return nil, OpErrors{{Index: index, Op: name, Message: err.Error()}}
+	}
+	return op, nil
+}
+
+// parseJSONDuration розбирає тривалість, задану числом секунд або рядком у форматі time.ParseDuration.
+func parseJSONDuration(raw json.RawMessage) (time.Duration, error) {
+	var d time.Duration
+	var secs float64
+	if err := json.Unmarshal(raw, &secs); err == nil {
+		if secs*float64(time.Second) > math.MaxInt64 {
+			return 0, fmt.Errorf("duration is out of range")
+		}
+		d = time.Duration(secs * float64(time.Second))
+	} else {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, fmt.Errorf("duration must be a number of seconds or a string")
+		}
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration must not be negative")
+	}
+	return d, nil
+}
+
+func (c *command) param(name string) (param, bool) {
+	for _, p := range c.params {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return param{}, false
+}
+
+// JSONSchema повертає JSON Schema запитів, які приймає ParseJSON. Схема будується з того самого опису команд, за
+// яким перевіряються запити.
+func JSONSchema() []byte {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	defs := map[string]any{
+		"duration": map[string]any{
+			"description": "seconds or a Go duration string such as 1.5s or 500ms",
+			"oneOf": []any{
+				map[string]any{"type": "number", "minimum": 0},
+				map[string]any{"type": "string"},
+			},
+		},
+		"colorString": map[string]any{
+			"type":        "string",
+			"description": "#RGB, #RGBA, #RRGGBB, #RRGGBBAA, rgb(r, g, b), rgba(r, g, b, a) or a CSS color name",
+		},
+	}
+	var variants []any
+	for _, name := range names {
+		cmd := commands[name]
+		props := map[string]any{"op": map[string]any{"const": name}}
+		required := append([]string{"op"}, cmd.require...)
+		for _, prm := range cmd.params {
+			switch prm.kind {
+			case coordArg:
+				props[prm.name] = map[string]any{"type": "number"}
+			case colorArg:
+				props[prm.name] = map[string]any{"$ref": "#/$defs/colorString"}
+			case durationArg:
+				props[prm.name] = map[string]any{"$ref": "#/$defs/duration"}
+			case countArg:
+				props[prm.name] = map[string]any{"type": "integer", "minimum": 1}
+			case nameArg, textArg:
+				props[prm.name] = map[string]any{"type": "string", "minLength": 1}
+			case pointsArg:
+				props[prm.name] = map[string]any{
+					"type":        "array",
+					"description": "x y pairs: [x1, y1, x2, y2, ...]",
+					"items":       map[string]any{"type": "number"},
+					"minItems":    2 * prm.min,
+				}
+			case valuesArg:
+				props[prm.name] = map[string]any{
+					"type":        "array",
+					"description": "numeric parameters followed by colors",
+					"items": map[string]any{
+						"oneOf": []any{map[string]any{"type": "number"}, map[string]any{"$ref": "#/$defs/colorString"}},
+					},
+					"minItems": prm.min,
+				}
+			}
+			if !prm.optional {
+				required = append(required, prm.name)
+			}
+		}
+		for key, allowed := range cmd.options {
+			switch {
+			case cmd.optKind(key) == coordArg:
+				props[key] = map[string]any{"type": "number"}
+			case cmd.optKind(key) == colorArg:
+				props[key] = map[string]any{"$ref": "#/$defs/colorString"}
+			case allowed == nil:
+				props[key] = map[string]any{"type": "string", "minLength": 1}
+			default:
+				props[key] = map[string]any{"enum": allowed}
+			}
+		}
+		defs[name] = map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+		variants = append(variants, map[string]any{"$ref": "#/$defs/" + name})
+	}
+
+	schema := map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "Painter operations",
+		"type":                 "object",
+		"required":             []string{"ops"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"ops": map[string]any{"type": "array", "items": map[string]any{"oneOf": variants}},
+		},
+		"$defs": defs,
+	}
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
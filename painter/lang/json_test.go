@@ -0,0 +1,143 @@
+package lang
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParser_ParseJSON_MatchesScript(t *testing.T) {
+	script := `
+		white
+		bgrect id=r mode=outline 0.25 0.25 0.75 0.75 rgba(0,0,0,0.5)
+		figure id=a 0.5 0.5 #ff0000
+		move id=a mode=rel 0.1 -0.1
+		raise id=r
+		animate figure=a ease=ease-in to 0 1 over 1.5s
+		checkpoint a; undo 2; restore a
+		group id=g; attach id=a group=g; rotate id=g mode=rel 30
+		polygon id=p stroke=#00f width=0.01 0 0 1 0 0.5 1; star 0.5 0.5 0.2 6
+		text align=right 0.9 0.1 0.05 "a\nb" blue
+		bg id=r radial 0.5 0.5 0.5 #fff red
+		update
+	`
+	request := `{"ops": [
+		{"op": "white"},
+		{"op": "bgrect", "id": "r", "mode": "outline", "x1": 0.25, "y1": 0.25, "x2": 0.75, "y2": 0.75, "color": "rgba(0,0,0,0.5)"},
+		{"op": "figure", "id": "a", "x": 0.5, "y": 0.5, "color": "#ff0000"},
+		{"op": "move", "id": "a", "mode": "rel", "x": 0.1, "y": -0.1},
+		{"op": "raise", "id": "r"},
+		{"op": "animate", "figure": "a", "ease": "ease-in", "x": 0, "y": 1, "duration": "1.5s"},
+		{"op": "checkpoint", "name": "a"},
+		{"op": "undo", "n": 2},
+		{"op": "restore", "name": "a"},
+		{"op": "group", "id": "g"},
+		{"op": "attach", "id": "a", "group": "g"},
+		{"op": "rotate", "id": "g", "mode": "rel", "angle": 30},
+		{"op": "polygon", "id": "p", "stroke": "#00f", "width": 0.01, "points": [0, 0, 1, 0, 0.5, 1]},
+		{"op": "star", "x": 0.5, "y": 0.5, "r": 0.2, "n": 6},
+		{"op": "text", "align": "right", "x": 0.9, "y": 0.1, "size": 0.05, "text": "a\nb", "color": "blue"},
+		{"op": "bg", "id": "r", "kind": "radial", "args": [0.5, 0.5, 0.5, "#fff", "red"]},
+		{"op": "update"}
+	]}`
+
+	parser := &Parser{}
+	expected, err := parser.Parse(strings.NewReader(script))
+	if err != nil {
+		t.Fatalf("script: unexpected error %v", err)
+	}
+	operations, err := parser.ParseJSON(strings.NewReader(request))
+	if err != nil {
+		t.Fatalf("json: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+}
+
+func TestParser_ParseJSON_Errors(t *testing.T) {
+	request := `{"ops": [
+		{"op": "white"},
+		{"op": "figure", "x": "0.5"},
+		{"op": "jump"},
+		{"op": "delete"},
+		{"op": "bgrect", "x1": 0, "y1": 0, "x2": 1, "y2": 1, "mode": "dotted", "z": 1},
+		{"op": "polygon", "points": [0, 0, 1, 0, 1]},
+		{"op": "bg", "kind": "linear", "args": [0, 0, 1, 1, "#fff", true]},
+		{"op": "circle", "x": 0.5, "y": 0.5, "r": 0.1, "width": "2.5"}
+	]}`
+
+	_, err := (&Parser{}).ParseJSON(strings.NewReader(request))
+	var errs OpErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected OpErrors, got %v", err)
+	}
+
+	type loc struct {
+		Index int
+		Field string
+	}
+	var got []loc
+	for _, e := range errs {
+		got = append(got, loc{e.Index, e.Field})
+	}
+	expected := []loc{{1, "x"}, {1, "y"}, {2, "op"}, {3, "id"}, {4, "mode"}, {4, "z"}, {5, "points"}, {6, "args"}, {7, "width"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected errors at %v, got %v", expected, errs)
+	}
+
+	for _, request := range []string{`{}`, `{"ops": [], "extra": 1}`, `[`, `{"ops": []} {}`} {
+		_, err := (&Parser{}).ParseJSON(strings.NewReader(request))
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Index != -1 {
+			t.Errorf("%s: expected a request-level error, got %v", request, err)
+		}
+	}
+}
+
+func TestJSONSchema_DescribesCommands(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Required   []string                  `json:"required"`
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(JSONSchema(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	for name := range commands {
+		if _, ok := schema.Defs[name]; !ok {
+			t.Errorf("schema does not describe command %s", name)
+		}
+	}
+	if req := schema.Defs["bgrect"].Required; !reflect.DeepEqual(req, []string{"op", "x1", "y1", "x2", "y2"}) {
+		t.Errorf("unexpected required fields for bgrect: %v", req)
+	}
+	// Числові опції передаються числами, як і параметри.
+	circle := schema.Defs["circle"].Properties
+	if circle["width"]["type"] != "number" || circle["fill"]["$ref"] != "#/$defs/colorString" || circle["id"]["type"] != "string" {
+		t.Errorf("unexpected option types for circle: %v", circle)
+	}
+}
+
+func TestJSONHandler_ReportsErrors(t *testing.T) {
+	h := JSONHandler(nil, &Parser{})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ops", strings.NewReader(`{"ops": [{"op": "figure"}]}`)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	var body struct {
+		Errors []OpError `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if len(body.Errors) != 2 || body.Errors[0].Index != 0 || body.Errors[0].Op != "figure" {
+		t.Errorf("unexpected errors: %+v", body.Errors)
+	}
+}
@@ -0,0 +1,268 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF     tokenKind = iota
+	tokNewline           // кінець рядка або ';'
+	tokIdent
+	tokNumber
+	tokPercent  // число зі знаком %, наприклад 50%
+	tokDuration // число з одиницею часу, наприклад 2s або 500ms
+	tokString
+	tokColor // #RGB, #RRGGBB тощо
+	tokPunct // оператори та розділові знаки
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of script"
+	case tokNewline:
+		return "end of line"
+	case tokIdent:
+		return "identifier"
+	case tokNumber, tokPercent:
+		return "number"
+	case tokDuration:
+		return "duration"
+	case tokString:
+		return "string"
+	case tokColor:
+		return "color"
+	default:
+		return "operator"
+	}
+}
+
+// token — лексема скрипта. space показує, що перед лексемою був пробіл: у списку аргументів команди пробіли
+// розділяють аргументи.
+type token struct {
+	kind  tokenKind
+	text  string // для рядків — вміст без лапок
+	line  int
+	col   int
+	space bool
+}
+
+func (t token) is(punct string) bool { return t.kind == tokPunct && t.text == punct }
+
+func (t token) String() string {
+	if t.kind == tokEOF || t.kind == tokNewline {
+		return t.kind.String()
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// puncts перелічені від довших до коротших, щоб ".." не розпізнавався як дві крапки.
+var puncts = []string{"..", "<=", ">=", "==", "!=", "&&", "||", "+", "-", "*", "/", "%", "(", ")", ",", "=", "<", ">", "!", "{", "}"}
+
+// lex розбиває скрипт на лексеми. Символ # починає коментар до кінця рядка, якщо тільки за ним не йде
+// шістнадцятковий колір (3, 4, 6 або 8 цифр), який не стоїть першим у рядку.
+func lex(src string) ([]token, ParseErrors) {
+	var (
+		toks      []token
+		errs      ParseErrors
+		line, col = 1, 1
+		space     = true
+		lineStart = true
+	)
+	emit := func(kind tokenKind, text string, l, c int) {
+		toks = append(toks, token{kind: kind, text: text, line: l, col: c, space: space})
+		space = false
+		lineStart = false
+	}
+
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		startLine, startCol := line, col
+		advance := func(n int) {
+			col += utf8.RuneCountInString(src[i : i+n])
+			i += n
+		}
+
+		switch {
+		case r == '\n' || r == ';':
+			emit(tokNewline, string(r), startLine, startCol)
+			i += size
+			if r == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+			space, lineStart = true, true
+
+		case unicode.IsSpace(r):
+			advance(size)
+			space = true
+
+		case r == '#':
+			n := hexRun(src[i+1:])
+			if !lineStart && (n == 3 || n == 4 || n == 6 || n == 8) {
+				emit(tokColor, src[i:i+1+n], startLine, startCol)
+				advance(1 + n)
+				continue
+			}
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			advance(end)
+			space = true
+
+		case r == '"':
+			text, n, err := lexString(src[i:])
+			if err != "" {
+				errs = append(errs, &ParseError{Line: startLine, Column: startCol, Message: err})
+			}
+			emit(tokString, text, startLine, startCol)
+			advance(n)
+
+		case unicode.IsDigit(r) || r == '.' && i+1 < len(src) && isDigit(src[i+1]):
+			n := numberLen(src[i:])
+			kind, unit := tokNumber, 0
+			if i+n < len(src) && src[i+n] == '%' && !continuesOperand(src[i+n+1:]) {
+				kind, unit = tokPercent, 1
+			} else if u := durationUnit(src[i+n:]); u > 0 {
+				kind, unit = tokDuration, u
+			}
+			if kind == tokDuration {
+				emit(kind, src[i:i+n+unit], startLine, startCol)
+			} else {
+				emit(kind, src[i:i+n], startLine, startCol)
+			}
+			advance(n + unit)
+
+		case r == '_' || unicode.IsLetter(r):
+			n := 0
+			for n < len(src)-i {
+				r, size := utf8.DecodeRuneInString(src[i+n:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				n += size
+			}
+			emit(tokIdent, src[i:i+n], startLine, startCol)
+			advance(n)
+
+		default:
+			matched := false
+			for _, p := range puncts {
+				if strings.HasPrefix(src[i:], p) {
+					emit(tokPunct, p, startLine, startCol)
+					advance(len(p))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				errs = append(errs, &ParseError{Line: startLine, Column: startCol, Message: fmt.Sprintf("unexpected character %q", r)})
+				advance(size)
+			}
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, line: line, col: col, space: true})
+	return toks, errs
+}
+
+func isDigit(b byte) bool { return b >= '0' && b <= '9' }
+
+func isHex(b byte) bool { return isDigit(b) || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F' }
+
+// hexRun повертає довжину шістнадцяткового кольору на початку s або 0, якщо за цифрами одразу йдуть інші символи
+// слова.
+func hexRun(s string) int {
+	n := 0
+	for n < len(s) && isHex(s[n]) {
+		n++
+	}
+	if n < len(s) && (s[n] == '_' || unicode.IsLetter(rune(s[n])) || isDigit(s[n])) {
+		return 0
+	}
+	return n
+}
+
+func numberLen(s string) int {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	if n < len(s) && s[n] == '.' && !strings.HasPrefix(s[n:], "..") {
+		n++
+		for n < len(s) && isDigit(s[n]) {
+			n++
+		}
+	}
+	if n < len(s) && (s[n] == 'e' || s[n] == 'E') {
+		m := n + 1
+		if m < len(s) && (s[m] == '+' || s[m] == '-') {
+			m++
+		}
+		if m < len(s) && isDigit(s[m]) {
+			for m < len(s) && isDigit(s[m]) {
+				m++
+			}
+			n = m
+		}
+	}
+	return n
+}
+
+// durationUnit повертає довжину одиниці часу ms або s на початку s або 0, якщо її немає.
+func durationUnit(s string) int {
+	for _, unit := range []string{"ms", "s"} {
+		if strings.HasPrefix(s, unit) && !startsWord(s[len(unit):]) {
+			return len(unit)
+		}
+	}
+	return 0
+}
+
+func startsWord(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return s != "" && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// continuesOperand повідомляє, чи починається s з операнда, тобто чи % у "a%b" є оператором остачі.
+func continuesOperand(s string) bool {
+	if s == "" {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || r == '(' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lexString розбирає рядок у лапках з екрануванням \", \\, \n та \t.
+func lexString(s string) (text string, n int, err string) {
+	var b strings.Builder
+	for n = 1; n < len(s); n++ {
+		switch c := s[n]; c {
+		case '"':
+			return b.String(), n + 1, ""
+		case '\n':
+			return b.String(), n, "unterminated string"
+		case '\\':
+			if n+1 < len(s) {
+				n++
+				switch s[n] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(s[n])
+				}
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), n, "unterminated string"
+}
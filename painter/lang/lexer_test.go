@@ -0,0 +1,40 @@
+package lang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want []string
+	}{
+		{"#fff comment\nfill #fff", []string{"\n", "fill", "#fff"}},
+		{"figure 50% 7%2", []string{"figure", "50", "7", "%", "2"}},
+		{"let s = \"a\\\"b\"", []string{"let", "s", "=", `a"b`}},
+		{"x 1e-3 .5 0..3", []string{"x", "1e-3", ".5", "0", "..", "3"}},
+		{"a>=b;c", []string{"a", ">=", "b", ";", "c"}},
+	} {
+		toks, errs := lex(tc.src)
+		if len(errs) > 0 {
+			t.Errorf("%q: unexpected errors: %v", tc.src, errs)
+			continue
+		}
+		var got []string
+		for _, tok := range toks[:len(toks)-1] {
+			got = append(got, tok.text)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: expected %q, got %q", tc.src, tc.want, got)
+		}
+	}
+
+	toks, _ := lex("fill 50%")
+	if toks[1].kind != tokPercent || toks[1].col != 6 {
+		t.Errorf("expected percent literal at column 6, got %+v", toks[1])
+	}
+	if _, errs := lex("fill \"open\nfill @"); len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", errs)
+	}
+}
@@ -1,118 +1,217 @@
 package lang
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
+	"sort"
 
 	"github.com/DmytroHalai/kpi-3/painter"
 )
 
+// Parser розбирає текстові та JSON скрипти. Обмеження захищають від скриптів, які розгортаються у надто велику
+// кількість операцій; нульове значення означає типове обмеження.
 type Parser struct {
+	MaxOps    int // кількість операцій у результаті, DefaultMaxOps
+	MaxSteps  int // кількість виконаних інструкцій та ітерацій циклів, DefaultMaxSteps
+	MaxDepth  int // вкладеність викликів процедур, DefaultMaxDepth
+	MaxString int // довжина рядкових значень у байтах, DefaultMaxString
+	MaxBytes  int // обсяг точок, значень і тексту всіх операцій у байтах, DefaultMaxBytes
+
+	SceneDir string // каталог файлів команд save та load; порожній рядок означає поточний каталог
 }
 
-const scale = 400
+// Parse розбирає скрипт. Інструкції розділяються переведенням рядка або ';', # починає коментар до кінця рядка,
+// let name = expr оголошує змінну, а аргументами команд можуть бути арифметичні вирази зі змінними, числами,
+// відсотками (50% = 0.5) та вбудованими функціями (sin, cos, sqrt, min, max, rgb, rgba тощо), наприклад:
+//
+//	let r = 0.1  # радіус
+//	figure 0.5+r*cos(pi/4) 50%
+//
+// Цикли, процедури та умови розгортаються під час розбору, див. script.go:
+//
+//	def dot(x, y) { figure x y; update }
+//	for i in 0..10 { if i % 2 == 0 { dot i/10 i/10 } }
+//
+// Розбір не зупиняється на першій помилці: якщо помилки є, повертається ParseErrors з усіма ними.
+func (p *Parser) Parse(in io.Reader) ([]painter.Operation, error) {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
 
-func (p *Parser) Parse(in io.Reader, scene *painter.Scene) ([]painter.Operation, error) {
-	var res []painter.Operation
-	scanner := bufio.NewScanner(in)
-	scanner.Split(bufio.ScanLines)
+	toks, errs := lex(string(src))
+	sp := &syntaxParser{toks: toks}
+	stmts := sp.script()
+	errs = append(errs, sp.errs...)
+
+	x := newExecutor(p)
+	_ = x.run(x.globals(), stmts)
+	errs = append(errs, x.errs...)
+
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			if errs[i].Line != errs[j].Line {
+				return errs[i].Line < errs[j].Line
+			}
+			return errs[i].Column < errs[j].Column
+		})
+		return nil, errs
+	}
+	return x.ops, nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+// parseCommand будує операцію команди та повертає також обсяг її даних у байтах, див. cmdArgs.size.
+func (p *Parser) parseCommand(vars *env, s *cmdStmt) (painter.Operation, int, ParseErrors) {
+	name := s.name
+	cmd, ok := commands[name.text]
+	if !ok {
+		return nil, 0, ParseErrors{{Line: name.line, Column: name.col, Command: name.text, Message: "unknown command"}}
+	}
+
+	var errs ParseErrors
+	lo, hi := cmd.arity()
+	detail := func(e *ParseError) {
+		e.Command = name.text
+		e.Expected = arityString(lo, hi)
+		e.Usage = cmd.usage(name.text)
+		errs = append(errs, e)
+	}
+	fail := func(t token, format string, args ...any) {
+		detail(errorAt(t, format, args...))
+	}
+	// Помилка змінної, про яку вже повідомлено в let, лише скасовує команду.
+	failed := false
+	evalFail := func(err error) {
+		failed = true
+		if pe := (*ParseError)(nil); errors.As(err, &pe) {
+			detail(pe)
 		}
+	}
 
-		parts := strings.Fields(line)
-		if len(parts) == 0 {
+	a := newCmdArgs(p)
+	var (
+		args     []cmdArg
+		prefixed = map[int]bool{} // індекси аргументів, перед якими стоїть слово param.prefix
+	)
+	for _, arg := range s.args {
+		if arg.key != "" {
+			if err := cmd.setOption(a, arg.key, arg.raw); err != nil {
+				fail(arg.tok, "%v", err)
+			}
 			continue
 		}
-
-		cmd, args := parts[0], parts[1:]
-
-		op, err := p.parseCommand(cmd, args, scene)
-		if err != nil {
-			return nil, err
+		if id, ok := arg.value.(*identExpr); ok && len(args) < len(cmd.params) && id.tok.text == cmd.params[len(args)].prefix {
+			prefixed[len(args)] = true
+			continue
 		}
-		res = append(res, op...)
+		args = append(args, arg)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	if key, missing := cmd.missingOption(a); missing {
+		fail(name, "option %s=<value> is required", key)
 	}
 
-	return res, nil
-}
-
-func (p *Parser) parseCommand(cmd string, args []string, scene *painter.Scene) ([]painter.Operation, error) {
-	switch cmd {
-	case "white", "green", "update", "reset":
-		if len(args) != 0 {
-			return nil, fmt.Errorf("%s command takes no arguments, got %d", cmd, len(args))
-		}
-		return []painter.Operation{p.simpleOp(cmd, scene)}, nil
-
-	case "bgrect":
-		if len(args) != 4 {
-			return nil, fmt.Errorf("bgrect command requires 4 arguments, got %d", len(args))
-		}
-		ints, err := parseArgsAsInts(args)
-		if err != nil {
-			return nil, fmt.Errorf("bgrect arg error: %v", err)
-		}
-		return []painter.Operation{painter.BgRectOp(scene, int(ints[0]*scale), int(ints[1]*scale), int(ints[2]*scale), int(ints[3]*scale))}, nil
-
-	case "figure":
-		if len(args) != 2 {
-			return nil, fmt.Errorf("figure command requires 2 arguments, got %d", len(args))
-		}
-		ints, err := parseArgsAsInts(args)
-		if err != nil {
-			return nil, fmt.Errorf("figure arg error: %v", err)
+	if len(args) < lo || hi >= 0 && len(args) > hi {
+		t := name
+		if hi >= 0 && len(args) > hi {
+			t = args[hi].tok
 		}
-		return []painter.Operation{painter.ShapeOp(scene, int(ints[0]*scale), int(ints[1]*scale))}, nil
-
-	case "move":
-		if len(args) != 2 {
-			return nil, fmt.Errorf("move command requires 2 arguments, got %d", len(args))
+		fail(t, "expected %s, got %d", arityString(lo, hi), len(args))
+		return nil, 0, errs
+	}
+	if hi < 0 && cmd.params[len(cmd.params)-1].kind == pointsArg && (len(args)-len(cmd.params)+1)%2 != 0 {
+		fail(args[len(args)-1].tok, "points must be given as x y pairs")
+		return nil, 0, errs
+	}
+	for i, arg := range args {
+		prm, _ := cmd.paramAt(i)
+		if prm.prefix != "" && !prefixed[i] {
+			fail(arg.tok, "expected %q before <%s>", prm.prefix, prm.name)
+			continue
 		}
-		ints, err := parseArgsAsInts(args)
-		if err != nil {
-			return nil, fmt.Errorf("move arg error: %v", err)
+		switch prm.kind {
+		case coordArg:
+			v, err := vars.evalNumber(arg.value)
+			if err != nil {
+				evalFail(err)
+				continue
+			}
+			a.coords[prm.name] = v
+		case colorArg:
+			c, err := vars.evalColor(arg.value)
+			if err != nil {
+				evalFail(err)
+				continue
+			}
+			a.colors[prm.name] = c
+		case durationArg:
+			d, err := vars.evalDuration(arg.value)
+			if err != nil {
+				evalFail(err)
+				continue
+			}
+			a.durations[prm.name] = d
+		case countArg:
+			n, err := vars.evalCount(arg.value)
+			if err != nil {
+				evalFail(err)
+				continue
+			}
+			a.counts[prm.name] = n
+		case nameArg:
+			n, err := vars.evalName(arg.value)
+			if err != nil {
+				evalFail(err)
+				continue
+			}
+			a.names[prm.name] = n
+		case pointsArg:
+			v, err := vars.evalNumber(arg.value)
+			if err != nil {
+				evalFail(err)
+				continue
+			}
+			a.pointSets[prm.name] = append(a.pointSets[prm.name], v)
+		case textArg:
+			s, err := vars.evalText(arg.value)
+			if err != nil {
+				evalFail(err)
+				continue
+			}
+			a.texts[prm.name] = s
+		case valuesArg:
+			v, err := vars.evalValue(arg.value)
+			if err != nil {
+				evalFail(err)
+				continue
+			}
+			a.values[prm.name] = append(a.values[prm.name], v)
 		}
-		return []painter.Operation{painter.MoveOp(scene, int(ints[0]*scale), int(ints[1]*scale))}, nil
-
-	default:
-		return nil, fmt.Errorf("unknown command: %s", cmd)
 	}
-}
+	if len(errs) > 0 || failed {
+		return nil, 0, errs
+	}
 
-func (p *Parser) simpleOp(cmd string, scene *painter.Scene) painter.Operation {
-	switch cmd {
-	case "white":
-		return painter.WhiteFill(scene)
-	case "green":
-		return painter.GreenFill(scene)
-	case "update":
-		return painter.UpdateOp
-	case "reset":
-		return painter.ResetOp(scene)
-	default:
-		panic("unreachable")
+	op, err := cmd.build(a)
+	if err != nil {
+		fail(name, "%v", err)
+		return nil, 0, errs
 	}
+	return op, a.size(), nil
 }
 
-func parseArgsAsInts(args []string) ([]float32, error) {
-	ints := make([]float32, len(args))
-	for i, arg := range args {
-		val, err := strconv.ParseFloat(arg, 64)
-		if err != nil {
-			return nil, fmt.Errorf("arg %d invalid: %v", i+1, err)
-		}
-		ints[i] = float32(val)
+func arityString(lo, hi int) string {
+	switch {
+	case hi < 0:
+		return fmt.Sprintf("at least %d arguments", lo)
+	case hi == 0:
+		return "no arguments"
+	case lo == 1 && hi == 1:
+		return "1 argument"
+	case lo == hi:
+		return fmt.Sprintf("%d arguments", lo)
+	default:
+		return fmt.Sprintf("%d to %d arguments", lo, hi)
 	}
-	return ints, nil
 }
@@ -1,19 +1,26 @@
 package lang
 
 import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DmytroHalai/kpi-3/painter"
+	"github.com/DmytroHalai/kpi-3/ui/headless"
 )
 
 func TestParser_Parse_White(t *testing.T) {
 	input := "white\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
-	expectedOperations := []painter.Operation{painter.WhiteFill(scene)}
+	expectedOperations := []painter.Operation{painter.WhiteFill()}
 
-	operations, err := parser.Parse(strings.NewReader(input), scene)
+	operations, err := parser.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -26,10 +33,9 @@ func TestParser_Parse_White(t *testing.T) {
 func TestParser_Parse_Green(t *testing.T) {
 	input := "green\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
-	expectedOperations := []painter.Operation{painter.GreenFill(scene)}
+	expectedOperations := []painter.Operation{painter.GreenFill()}
 
-	operations, err := parser.Parse(strings.NewReader(input), scene)
+	operations, err := parser.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -42,10 +48,9 @@ func TestParser_Parse_Green(t *testing.T) {
 func TestParser_Parse_Update(t *testing.T) {
 	input := "update\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
 	expectedOperations := []painter.Operation{painter.UpdateOp}
 
-	operations, err := parser.Parse(strings.NewReader(input), scene)
+	operations, err := parser.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -58,10 +63,9 @@ func TestParser_Parse_Update(t *testing.T) {
 func TestParser_Parse_BgRect(t *testing.T) {
 	input := "bgrect 0 0 10 10\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
-	expectedOperations := []painter.Operation{painter.BgRectOp(scene, 0, 0, 10, 10)}
+	expectedOperations := []painter.Operation{painter.BgRectOp(0, 0, 10, 10)}
 
-	operations, err := parser.Parse(strings.NewReader(input), scene)
+	operations, err := parser.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -74,10 +78,9 @@ func TestParser_Parse_BgRect(t *testing.T) {
 func TestParser_Parse_Figure(t *testing.T) {
 	input := "figure 5 5\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
-	expectedOperations := []painter.Operation{painter.ShapeOp(scene, 5, 5)}
+	expectedOperations := []painter.Operation{painter.ShapeOp(5, 5)}
 
-	operations, err := parser.Parse(strings.NewReader(input), scene)
+	operations, err := parser.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -90,10 +93,9 @@ func TestParser_Parse_Figure(t *testing.T) {
 func TestParser_Parse_Move(t *testing.T) {
 	input := "move 10 15\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
-	expectedOperations := []painter.Operation{painter.MoveOp(scene, 10, 15)}
+	expectedOperations := []painter.Operation{painter.MoveOp(10, 15)}
 
-	operations, err := parser.Parse(strings.NewReader(input), scene)
+	operations, err := parser.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -106,10 +108,9 @@ func TestParser_Parse_Move(t *testing.T) {
 func TestParser_Parse_Reset(t *testing.T) {
 	input := "reset\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
-	expectedOperations := []painter.Operation{painter.ResetOp(scene)}
+	expectedOperations := []painter.Operation{painter.ResetOp()}
 
-	operations, err := parser.Parse(strings.NewReader(input), scene)
+	operations, err := parser.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -128,9 +129,8 @@ func TestParser_Parse_MultipleCommands(t *testing.T) {
 	`
 
 	parser := &Parser{}
-	scene := &painter.Scene{}
 
-	operations, err := parser.Parse(strings.NewReader(input), scene)
+	operations, err := parser.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -140,13 +140,145 @@ func TestParser_Parse_MultipleCommands(t *testing.T) {
 	}
 }
 
+func TestParser_Parse_OperationValues(t *testing.T) {
+	input := "bgrect 0.25 0.25 0.75 0.75\nfigure 0.5 0.5\n"
+	parser := &Parser{}
+
+	operations, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{painter.BgRectOp(0.25, 0.25, 0.75, 0.75), painter.ShapeOp(0.5, 0.5)}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+}
+
+func TestParser_Parse_ShapeAddressing(t *testing.T) {
+	input := `
+		figure id=a 0.5 0.5
+		move id=a 0.25 0.75
+		move id=a mode=rel 0.1 0
+		color id=a #ff000080
+		delete id=a
+	`
+	parser := &Parser{}
+
+	operations, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.AddShape{ID: "a", X: 0.5, Y: 0.5},
+		painter.MoveShapes{ID: "a", X: 0.25, Y: 0.75},
+		painter.MoveShapes{ID: "a", X: 0.1, Y: 0, Relative: true},
+		painter.Recolor{ID: "a", Color: color.NRGBA{R: 255, A: 128}},
+		painter.Delete{ID: "a"},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+}
+
+func TestParser_Parse_RectLayers(t *testing.T) {
+	input := `
+		bgrect id=r mode=outline 0 0 0.5 0.5
+		raise id=r
+		lower id=r
+		delete id=r
+		bgclear
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.AddRect{ID: "r", X2: 0.5, Y2: 0.5, Outline: true},
+		painter.Raise{ID: "r"},
+		painter.Lower{ID: "r"},
+		painter.Delete{ID: "r"},
+		painter.ClearRects{},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+}
+
+func TestParser_Parse_Colors(t *testing.T) {
+	input := `
+		fill rgba(0, 0, 255, 0.5)
+		bgrect 0 0 1 1 white
+		figure 0.5 0.5 #ff0000
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.Fill{Color: color.NRGBA{B: 255, A: 128}},
+		painter.AddRect{X2: 1, Y2: 1, Color: color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		painter.AddShape{X: 0.5, Y: 0.5, Color: color.NRGBA{R: 255, A: 255}},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+}
+
+func TestParser_Parse_InvalidOptions(t *testing.T) {
+	for _, input := range []string{
+		"figure name=a 0.5 0.5",
+		"move mode=sideways 0 0",
+		"delete",
+		"color id=a notacolor",
+		"figure id=a id=b 0 0",
+		"bgrect mode=dotted 0 0 1 1",
+		"raise",
+		"bgclear 1",
+		"fill",
+		"fill #12345",
+		"figure 0 0 red blue",
+	} {
+		if _, err := (&Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
+
+func TestParser_Parse_CollectsErrors(t *testing.T) {
+	input := "white\n  jump 1\nfigure 0.5\n\nbgrect 0 x 1 1 nocolor\nmove 1 2 3\n"
+
+	_, err := (&Parser{}).Parse(strings.NewReader(input))
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ParseErrors, got %v", err)
+	}
+
+	type loc struct {
+		Line, Column int
+		Command      string
+	}
+	var got []loc
+	for _, e := range errs {
+		got = append(got, loc{e.Line, e.Column, e.Command})
+	}
+	expected := []loc{{2, 3, "jump"}, {3, 1, "figure"}, {5, 10, "bgrect"}, {5, 16, "bgrect"}, {6, 10, "move"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected errors at %v, got:\n%v", expected, err)
+	}
+	if e := errs[1]; e.Expected != "2 to 3 arguments" || e.Usage != "figure [group=<group>] [id=<id>] <x> <y> [<color>]" {
+		t.Errorf("unexpected arity details: %+v", e)
+	}
+}
 
 func TestParser_Parse_InvalidCommand(t *testing.T) {
 	input := "invalid 1 2\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
 
-	_, err := parser.Parse(strings.NewReader(input), scene)
+	_, err := parser.Parse(strings.NewReader(input))
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
@@ -155,16 +287,479 @@ func TestParser_Parse_InvalidCommand(t *testing.T) {
 func TestParser_Parse_InvalidArguments(t *testing.T) {
 	input := "bgrect 0 0\n"
 	parser := &Parser{}
-	scene := &painter.Scene{}
 
-	_, err := parser.Parse(strings.NewReader(input), scene)
+	_, err := parser.Parse(strings.NewReader(input))
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
 
 	input = "figure x y\n"
-	_, err = parser.Parse(strings.NewReader(input), scene)
+	_, err = parser.Parse(strings.NewReader(input))
 	if err == nil {
 		t.Fatalf("expected error, got none")
 	}
 }
+
+func TestParser_Parse_Expressions(t *testing.T) {
+	input := `
+		# коментар на весь рядок
+		let r = 0.25   # радіус
+		let c = rgb(255, 0, 0)
+		figure cx+r cy - r c; update
+		bgrect 0 0 50% r*2 #00f
+		move mode=rel (1 - r) / 2 min(0.1, r)
+		fill rgba(0, 0, 255, 50%)
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.AddShape{X: 0.75, Y: 0.25, Color: color.NRGBA{R: 255, A: 255}},
+		painter.UpdateOp,
+		painter.AddRect{X2: 0.5, Y2: 0.5, Color: color.NRGBA{B: 255, A: 255}},
+		painter.MoveShapes{X: 0.375, Y: 0.1, Relative: true},
+		painter.Fill{Color: color.NRGBA{B: 255, A: 128}},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+}
+
+func TestParser_Parse_ExpressionErrors(t *testing.T) {
+	input := "let a = 1/0\nfigure a a\nfigure (0.5 0.5\nfigure sqrt(1, 2) foo\nlet = 1\n"
+
+	_, err := (&Parser{}).Parse(strings.NewReader(input))
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ParseErrors, got %v", err)
+	}
+
+	type loc struct{ Line, Column int }
+	var got []loc
+	for _, e := range errs {
+		got = append(got, loc{e.Line, e.Column})
+	}
+	// Помилка в let повідомляється один раз, а не при кожному використанні змінної.
+	expected := []loc{{1, 10}, {3, 13}, {4, 8}, {4, 19}, {5, 5}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected errors at %v, got:\n%v", expected, err)
+	}
+}
+
+func TestParser_Parse_ControlFlow(t *testing.T) {
+	input := `
+		def dot(x, y, c) {
+			figure x y c
+		}
+		repeat 2 { update }
+		for i in 0..4 {
+			if i % 2 == 0 && i > 0 {
+				dot i/4 0 red
+			} else if i == 3 { move 0 0 } else {
+				let j = i * 2
+				bgrect 0 0 j/4 j/4
+			}
+		}
+		def depth(n) { if n > 0 { depth n-1 } else { reset } }
+		depth 3
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	expected := []painter.Operation{
+		painter.UpdateOp,
+		painter.UpdateOp,
+		painter.AddRect{},
+		painter.AddRect{X2: 0.5, Y2: 0.5},
+		painter.AddShape{X: 0.5, Color: red},
+		painter.MoveShapes{},
+		painter.ResetOp(),
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+}
+
+func TestParser_Parse_ControlFlowErrors(t *testing.T) {
+	input := "for i in 0..3 {\n  figure i foo\n}\nif 1 { white }\nrepeat 2 {\nwhite\ndef figure() {}\n"
+
+	_, err := (&Parser{}).Parse(strings.NewReader(input))
+	var errs ParseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ParseErrors, got %v", err)
+	}
+	type loc struct{ Line, Column int }
+	var got []loc
+	for _, e := range errs {
+		got = append(got, loc{e.Line, e.Column})
+	}
+	// Помилка в тілі циклу повідомляється один раз.
+	expected := []loc{{2, 12}, {4, 4}, {5, 10}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected errors at %v, got:\n%v", expected, err)
+	}
+}
+
+func TestParser_Parse_Limits(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{"ops", "repeat 200 { white }"},
+		{"repeat", "repeat 1e300 {}"},
+		{"repeat NaN", "repeat 0/0 {}"},
+		{"steps", "for i in 0..1e12 {}"},
+		{"recursion", "def f() { f }\nf"},
+		{"string", "def f(s, n) { if n > 0 { f s+s n-1 } }\nf \"a\" 30"},
+		{"nesting", "let y = " + strings.Repeat("(", 1000) + "1" + strings.Repeat(")", 1000)},
+	} {
+		p := &Parser{MaxOps: 100, MaxSteps: 1000}
+		_, err := p.Parse(strings.NewReader("let x = 0\n" + tc.input))
+		if err == nil {
+			t.Errorf("%s: expected error, got none", tc.name)
+		}
+	}
+	if ops, err := (&Parser{MaxOps: 3}).Parse(strings.NewReader("repeat 3 { white }")); err != nil || len(ops) != 3 {
+		t.Errorf("expected 3 operations within the limit, got %d, %v", len(ops), err)
+	}
+
+	// Небагато операцій з тисячами точок кожна вичерпали б пам'ять без обмеження обсягу даних.
+	huge := "repeat 2000 { polygon" + strings.Repeat(" 0", 20000) + " }"
+	if _, err := (&Parser{}).Parse(strings.NewReader(huge)); err == nil || !strings.Contains(err.Error(), "bytes of data") {
+		t.Errorf("expected a data limit error, got %v", err)
+	}
+}
+
+func TestParser_Parse_Animate(t *testing.T) {
+	input := `
+		animate figure=a to 0.75 0.75 over 2s ease=in-out
+		animate to cx cy over 250ms
+		animate ease=cubic-bezier(0.1, 0.7, 1, 0.1) to 0 0 over 1
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.Animate{ID: "a", X: 0.75, Y: 0.75, Duration: 2 * time.Second, Easing: painter.EaseInOut},
+		painter.Animate{X: 0.5, Y: 0.5, Duration: 250 * time.Millisecond, Easing: painter.Linear},
+		painter.Animate{Duration: time.Second, Easing: painter.CubicBezier{X1: 0.1, Y1: 0.7, X2: 1, Y2: 0.1}},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+
+	for _, input := range []string{
+		"animate 0.5 0.5 over 1s",
+		"animate to 0.5 0.5 1s",
+		"animate to 0.5 0.5 over -1s",
+		"animate to 0.5 0.5 over red",
+		"animate ease=bounce to 0.5 0.5 over 1s",
+		"animate ease=cubic-bezier(2,0,1,1) to 0.5 0.5 over 1s",
+	} {
+		if _, err := (&Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
+
+func TestParser_Parse_History(t *testing.T) {
+	input := `
+		checkpoint start
+		let steps = 2
+		undo; redo steps
+		checkpoint "before move"
+		restore start
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.Checkpoint{Name: "start"},
+		painter.Undo{},
+		painter.Redo{N: 2},
+		painter.Checkpoint{Name: "before move"},
+		painter.Restore{Name: "start"},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+
+	for _, input := range []string{
+		"undo 0",
+		"undo 1.5",
+		"redo red",
+		"checkpoint",
+		"checkpoint 1",
+		`restore ""`,
+	} {
+		if _, err := (&Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
+
+func TestParser_Parse_SaveLoad(t *testing.T) {
+	p := &Parser{SceneDir: "scenes"}
+	operations, err := p.Parse(strings.NewReader(`save draft; load "v2_final-1"`))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := []painter.Operation{
+		painter.Save{Path: filepath.Join("scenes", "draft.json")},
+		loadScene{Path: filepath.Join("scenes", "v2_final-1.json")},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+
+	for _, input := range []string{
+		`save "../etc/passwd"`,
+		`load "a/b"`,
+		`save "my scene"`,
+		"load",
+	} {
+		if _, err := p.Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
+
+func TestParser_Parse_Groups(t *testing.T) {
+	input := `
+		group id=g; group id=inner parent=g
+		figure group=inner id=a 0.1 0.1
+		bgrect group=g 0 0 0.1 0.1
+		attach id=a group=g; detach id=a
+		translate id=g 0.25 0; translate id=g mode=rel 0 0.1
+		rotate id=g mode=rel 45
+		scale id=g 2; scale id=g 1 0.5
+		pivot id=g 0.5 0.5
+		ungroup id=inner
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.AddGroup{ID: "g"},
+		painter.AddGroup{ID: "inner", Parent: "g"},
+		painter.AddShape{ID: "a", X: 0.1, Y: 0.1, Parent: "inner"},
+		painter.AddRect{X1: 0, Y1: 0, X2: 0.1, Y2: 0.1, Parent: "g"},
+		painter.Attach{ID: "a", Group: "g"},
+		painter.Attach{ID: "a"},
+		painter.TranslateGroup{ID: "g", X: 0.25},
+		painter.TranslateGroup{ID: "g", Y: 0.1, Relative: true},
+		painter.RotateGroup{ID: "g", Angle: 45, Relative: true},
+		painter.ScaleGroup{ID: "g", X: 2, Y: 2},
+		painter.ScaleGroup{ID: "g", X: 1, Y: 0.5},
+		painter.PivotGroup{ID: "g", X: 0.5, Y: 0.5},
+		painter.Ungroup{ID: "inner"},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+
+	for _, input := range []string{
+		"group",
+		"attach id=a",
+		"translate 0 0",
+		"rotate id=g",
+		"scale id=g mode=abs 1 2 3",
+	} {
+		if _, err := (&Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
+
+func TestParser_Parse_Primitives(t *testing.T) {
+	input := `
+		circle id=c fill=red 0.5 0.5 0.1
+		ellipse stroke=#00f width=0.01 0.5 0.5 0.2 0.1
+		line group=g 0 0 1 1
+		polyline 0 0 0.5 0.5 1 0
+		polygon fill=rgb(0,255,0) 0 0 1 0 1 1 0 1
+		triangle 0 0 1 0 0 1
+		star 0.5 0.5 0.2; star 0.5 0.5 0.2 6 0.4
+		roundrect 0.1 0.1 0.9 0.9 0.05
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.AddPrimitive{ID: "c", Kind: "circle", Params: []float64{0.5, 0.5, 0.1}, Fill: color.RGBA{R: 255, A: 255}},
+		painter.AddPrimitive{Kind: "ellipse", Params: []float64{0.5, 0.5, 0.2, 0.1}, Stroke: color.NRGBA{B: 255, A: 255}, Width: 0.01},
+		painter.AddPrimitive{Kind: "line", Params: []float64{0, 0, 1, 1}, Parent: "g"},
+		painter.AddPrimitive{Kind: "polyline", Params: []float64{0, 0, 0.5, 0.5, 1, 0}},
+		painter.AddPrimitive{Kind: "polygon", Params: []float64{0, 0, 1, 0, 1, 1, 0, 1}, Fill: color.NRGBA{G: 255, A: 255}},
+		painter.AddPrimitive{Kind: "triangle", Params: []float64{0, 0, 1, 0, 0, 1}},
+		painter.AddPrimitive{Kind: "star", Params: []float64{0.5, 0.5, 0.2}},
+		painter.AddPrimitive{Kind: "star", Params: []float64{0.5, 0.5, 0.2, 6, 0.4}},
+		painter.AddPrimitive{Kind: "roundrect", Params: []float64{0.1, 0.1, 0.9, 0.9, 0.05}},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+
+	for _, input := range []string{
+		"circle 0.5 0.5",
+		"polygon 0 0 1 0",
+		"polyline 0 0 1",
+		"star 0.5 0.5 0.2 1",
+		"circle fill=nocolor 0.5 0.5 0.1",
+		"circle width=-1 0.5 0.5 0.1",
+	} {
+		if _, err := (&Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+
+	// move переміщує примітиви так само, як фігури.
+	operations, err = (&Parser{}).Parse(strings.NewReader("circle id=c1 0.5 0.5 0.1\nmove id=c1 0.2 0.3\nmove id=c1 mode=rel 0.1 0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s painter.Scene
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(10, 10))
+	for _, op := range operations {
+		op.Do(tx, &s)
+	}
+	if c := s.Primitives[0]; math.Abs(c.X+0.2) > 1e-9 || math.Abs(c.Y+0.2) > 1e-9 || c.Params[0] != 0.5 {
+		t.Errorf("circle was not moved: %+v", c)
+	}
+
+	_, err = (&Parser{}).Parse(strings.NewReader("polygon 0 0 1"))
+	var errs ParseErrors
+	if !errors.As(err, &errs) || errs[0].Expected != "at least 6 arguments" ||
+		errs[0].Usage != "polygon [fill=<fill>] [group=<group>] [id=<id>] [stroke=<stroke>] [width=<width>] <x1> <y1> <x2> <y2> <x3> <y3> [<x> <y> ...]" {
+		t.Errorf("unexpected arity details: %+v", errs)
+	}
+}
+
+func TestParser_Parse_Text(t *testing.T) {
+	input := `
+		text 0.1 0.1 0.05 "Title"
+		text id=n align=center font=bold spacing=1.5 group=g 0.5 0.5 5% "first\nsecond" #f00
+		for i in 1..2 { text i/10 0.9 0.03 i }
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []painter.Operation{
+		painter.AddText{X: 0.1, Y: 0.1, Size: 0.05, Text: "Title"},
+		painter.AddText{
+			ID: "n", X: 0.5, Y: 0.5, Size: 0.05, Text: "first\nsecond", Color: color.NRGBA{R: 255, A: 255},
+			Align: painter.AlignCenter, Font: "bold", Spacing: 1.5, Parent: "g",
+		},
+		painter.AddText{X: 0.1, Y: 0.9, Size: 0.03, Text: "1"},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+
+	for _, input := range []string{
+		`text 0.1 0.1 0.05`,
+		`text 0.1 0.1 0.05 ""`,
+		`text 0.1 0.1 0 "zero"`,
+		`text 0.1 0.1 20 "huge"`,
+		`text align=justify 0.1 0.1 0.05 "a"`,
+		`text font=comic 0.1 0.1 0.05 "a"`,
+		`text spacing=0 0.1 0.1 0.05 "a"`,
+	} {
+		if _, err := (&Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
+
+func TestParser_Parse_Image(t *testing.T) {
+	if err := painter.StoreAsset("logo", image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	input := `
+		image logo 0.1 0.2
+		image id=l group=g alpha=0.5 "logo" 0.5 0.5 10% 0.2
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := []painter.Operation{
+		painter.AddImage{Asset: "logo", X: 0.1, Y: 0.2},
+		painter.AddImage{ID: "l", Asset: "logo", X: 0.5, Y: 0.5, W: 0.1, H: 0.2, Alpha: 0.5, Parent: "g"},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+
+	for _, input := range []string{
+		`image logo 0.1`,
+		`image logo 0.1 0.1 0.5`,
+		`image missing 0.1 0.1`,
+		`image "../logo" 0.1 0.1`,
+		`image alpha=2 logo 0.1 0.1`,
+		`image logo 0.1 0.1 0 0.5`,
+	} {
+		if _, err := (&Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
+
+func TestParser_Parse_Bg(t *testing.T) {
+	input := `
+		bg linear 0 0 1 1 #fff #000
+		let c = "red"
+		bg id=r stripes 5% 45 c white blue
+		bg checker 0.1 black rgb(255, 255, 255)
+	`
+	operations, err := (&Parser{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	white, black := color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBA{A: 255}
+	expected := []painter.Operation{
+		painter.FillPaint{Paint: painter.Paint{
+			Kind: "linear", Params: []float64{0, 0, 1, 1}, Colors: []color.Color{white, black},
+		}},
+		painter.FillPaint{ID: "r", Paint: painter.Paint{
+			Kind: "stripes", Params: []float64{0.05, 45},
+			Colors: []color.Color{
+				color.RGBA{R: 255, A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}, color.RGBA{B: 255, A: 255},
+			},
+		}},
+		painter.FillPaint{Paint: painter.Paint{
+			Kind: "checker", Params: []float64{0.1}, Colors: []color.Color{color.RGBA{A: 255}, white},
+		}},
+	}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, operations)
+	}
+
+	for _, input := range []string{
+		`bg linear 0 0 #fff #000`,
+		`bg conic 0.5 #fff #000`,
+		`bg checker 0.1 #fff`,
+		`bg checker #fff 0.1 #000`,
+		`bg radial 0.5 0.5 0 #fff #000`,
+		`bg stripes 0.1 #fff true`,
+	} {
+		if _, err := (&Parser{}).Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got none", input)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Синтаксичне дерево скрипта. Скрипт складається з інструкцій, розділених переведенням рядка або ';':
+//
+//	let name = expr
+//	command [key=value ...] [arg ...]
+//	repeat expr { ... }
+//	for name in expr..expr { ... }
+//	def name(param, ...) { ... }
+//	if expr { ... } else if expr { ... } else { ... }
+//
+// Виклик процедури, оголошеної через def, записується так само, як команда: "name arg ...".
+//
+// Аргументи команд є виразами й розділяються пробілами: "figure cx+0.1 cy" має два аргументи. Бінарний оператор з
+// пробілом лише перед ним починає новий аргумент ("x -0.1" — це два аргументи), а з пробілами з обох боків
+// продовжує вираз ("x - 0.1"). Усередині дужок пробіли не мають значення.
+
+type stmt interface {
+	pos() token
+}
+
+type letStmt struct {
+	name  token
+	value expr
+}
+
+type cmdStmt struct {
+	name token
+	args []cmdArg
+}
+
+// cmdArg — позиційний аргумент (value) або іменована опція key=raw.
+type cmdArg struct {
+	tok   token
+	key   string
+	raw   string
+	value expr
+}
+
+type repeatStmt struct {
+	kw    token
+	count expr
+	body  []stmt
+}
+
+// forStmt повторює body для name від from включно до to не включно з кроком 1.
+type forStmt struct {
+	kw, name token
+	from, to expr
+	body     []stmt
+}
+
+type defStmt struct {
+	kw, name token
+	params   []token
+	body     []stmt
+}
+
+// ifStmt містить else if як вкладений ifStmt в els.
+type ifStmt struct {
+	kw   token
+	cond expr
+	then []stmt
+	els  []stmt
+}
+
+func (s *letStmt) pos() token    { return s.name }
+func (s *cmdStmt) pos() token    { return s.name }
+func (s *repeatStmt) pos() token { return s.kw }
+func (s *forStmt) pos() token    { return s.kw }
+func (s *defStmt) pos() token    { return s.kw }
+func (s *ifStmt) pos() token     { return s.kw }
+
+type expr interface {
+	pos() token
+}
+
+type (
+	literal struct {
+		tok token
+		val value
+	}
+	identExpr struct{ tok token }
+	unaryExpr struct {
+		op token
+		x  expr
+	}
+	binaryExpr struct {
+		op   token
+		x, y expr
+	}
+	callExpr struct {
+		name token
+		args []expr
+	}
+)
+
+func (e *literal) pos() token    { return e.tok }
+func (e *identExpr) pos() token  { return e.tok }
+func (e *unaryExpr) pos() token  { return e.op }
+func (e *binaryExpr) pos() token { return e.x.pos() }
+func (e *callExpr) pos() token   { return e.name }
+
+// maxNesting обмежує вкладеність блоків та дужок, щоб розбір глибоко вкладеного скрипта не вичерпав стек.
+const maxNesting = 100
+
+// keywords не можуть бути назвами змінних чи процедур.
+var keywords = map[string]bool{"let": true, "repeat": true, "for": true, "in": true, "def": true, "if": true, "else": true}
+
+// syntaxParser будує синтаксичне дерево з лексем.
+type syntaxParser struct {
+	toks  []token
+	i     int
+	errs  ParseErrors
+	depth int
+}
+
+// nest збільшує глибину вкладеності; виклик має супроводжуватись defer p.unnest().
+func (p *syntaxParser) nest(t token) error {
+	p.depth++
+	if p.depth > maxNesting {
+		return errorAt(t, "nesting is deeper than %d levels", maxNesting)
+	}
+	return nil
+}
+
+func (p *syntaxParser) unnest() { p.depth-- }
+
+func (p *syntaxParser) peek() token { return p.toks[p.i] }
+
+func (p *syntaxParser) peekAt(n int) token {
+	if p.i+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.i+n]
+}
+
+func (p *syntaxParser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func errorAt(t token, format string, args ...any) *ParseError {
+	return &ParseError{Line: t.line, Column: t.col, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *syntaxParser) expect(punct string) (token, error) {
+	t := p.next()
+	if !t.is(punct) {
+		return t, errorAt(t, "expected %q, got %s", punct, t)
+	}
+	return t, nil
+}
+
+// atStmtEnd повідомляє, чи закінчилась поточна інструкція. Інструкцію також завершує } в кінці блоку.
+func (p *syntaxParser) atStmtEnd() bool {
+	t := p.peek()
+	return t.kind == tokNewline || t.kind == tokEOF || t.is("}")
+}
+
+// skipLine пропускає лексеми до кінця інструкції після синтаксичної помилки.
+func (p *syntaxParser) skipLine() {
+	for !p.atStmtEnd() {
+		p.next()
+	}
+}
+
+func (p *syntaxParser) skipNewlines() {
+	for p.peek().kind == tokNewline {
+		p.next()
+	}
+}
+
+// script розбирає всі інструкції. Після помилки розбір продовжується з наступного рядка.
+func (p *syntaxParser) script() []stmt {
+	return p.stmts()
+}
+
+// stmts розбирає інструкції до кінця скрипта або до } і не поглинає їх. Після помилки розбір продовжується з
+// наступного рядка.
+func (p *syntaxParser) stmts() []stmt {
+	var res []stmt
+	for {
+		p.skipNewlines()
+		if t := p.peek(); t.kind == tokEOF {
+			return res
+		} else if t.is("}") {
+			if p.depth > 0 {
+				return res
+			}
+			p.errs = append(p.errs, errorAt(t, "unexpected %s", t))
+			p.next()
+			continue
+		}
+		s, err := p.statement()
+		if err != nil {
+			p.errs = append(p.errs, err.(*ParseError))
+			p.skipLine()
+			continue
+		}
+		res = append(res, s)
+	}
+}
+
+// block розбирає { інструкції }.
+func (p *syntaxParser) block() ([]stmt, error) {
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.nest(open); err != nil {
+		return nil, err
+	}
+	defer p.unnest()
+	body := p.stmts()
+	if _, err := p.expect("}"); err != nil {
+		return nil, errorAt(open, "block is not closed")
+	}
+	return body, nil
+}
+
+func (p *syntaxParser) statement() (stmt, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return nil, errorAt(t, "expected a command, got %s", t)
+	}
+	var (
+		s   stmt
+		err error
+	)
+	switch t.text {
+	case "let":
+		s, err = p.let()
+	case "repeat":
+		s, err = p.repeat(t)
+	case "for":
+		s, err = p.forLoop(t)
+	case "def":
+		s, err = p.def(t)
+	case "if":
+		s, err = p.ifElse(t)
+	case "in", "else":
+		err = errorAt(t, "unexpected %q", t.text)
+	default:
+		s, err = p.command(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !p.atStmtEnd() {
+		return nil, errorAt(p.peek(), "unexpected %s", p.peek())
+	}
+	return s, nil
+}
+
+// name розбирає назву змінної, параметра чи процедури.
+func (p *syntaxParser) name(what string) (token, error) {
+	t := p.next()
+	if t.kind != tokIdent || keywords[t.text] {
+		return t, errorAt(t, "expected a %s name, got %s", what, t)
+	}
+	return t, nil
+}
+
+func (p *syntaxParser) let() (stmt, error) {
+	name, err := p.name("variable")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("="); err != nil {
+		return nil, err
+	}
+	value, err := p.expr(false)
+	if err != nil {
+		return nil, err
+	}
+	return &letStmt{name: name, value: value}, nil
+}
+
+func (p *syntaxParser) repeat(kw token) (stmt, error) {
+	count, err := p.expr(false)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	return &repeatStmt{kw: kw, count: count, body: body}, nil
+}
+
+func (p *syntaxParser) forLoop(kw token) (stmt, error) {
+	name, err := p.name("variable")
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != tokIdent || t.text != "in" {
+		return nil, errorAt(t, "expected \"in\", got %s", t)
+	}
+	from, err := p.expr(false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(".."); err != nil {
+		return nil, err
+	}
+	to, err := p.expr(false)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	return &forStmt{kw: kw, name: name, from: from, to: to, body: body}, nil
+}
+
+func (p *syntaxParser) def(kw token) (stmt, error) {
+	name, err := p.name("procedure")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	d := &defStmt{kw: kw, name: name}
+	if !p.peek().is(")") {
+		for {
+			prm, err := p.name("parameter")
+			if err != nil {
+				return nil, err
+			}
+			d.params = append(d.params, prm)
+			t := p.next()
+			if t.is(")") {
+				break
+			}
+			if !t.is(",") {
+				return nil, errorAt(t, "expected \",\" or \")\", got %s", t)
+			}
+		}
+	} else {
+		p.next()
+	}
+	if d.body, err = p.block(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (p *syntaxParser) ifElse(kw token) (stmt, error) {
+	cond, err := p.expr(false)
+	if err != nil {
+		return nil, err
+	}
+	s := &ifStmt{kw: kw, cond: cond}
+	if s.then, err = p.block(); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokIdent || t.text != "else" {
+		return s, nil
+	}
+	p.next()
+	if t := p.peek(); t.kind == tokIdent && t.text == "if" {
+		p.next()
+		if err := p.nest(t); err != nil {
+			return nil, err
+		}
+		defer p.unnest()
+		els, err := p.ifElse(t)
+		if err != nil {
+			return nil, err
+		}
+		s.els = []stmt{els}
+		return s, nil
+	}
+	if s.els, err = p.block(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (p *syntaxParser) command(name token) (stmt, error) {
+	cmd := &cmdStmt{name: name}
+	for !p.atStmtEnd() {
+		t := p.peek()
+		if !t.space {
+			return nil, errorAt(t, "unexpected %s", t)
+		}
+		if eq := p.peekAt(1); t.kind == tokIdent && eq.is("=") && !eq.space {
+			p.next()
+			p.next()
+			cmd.args = append(cmd.args, cmdArg{tok: t, key: t.text, raw: p.rawValue()})
+			continue
+		}
+		value, err := p.expr(true)
+		if err != nil {
+			return nil, err
+		}
+		cmd.args = append(cmd.args, cmdArg{tok: t, value: value})
+	}
+	return cmd, nil
+}
+
+// rawValue збирає текст лексем, які йдуть підряд без пробілів, наприклад значення опції ease=in-out.
+func (p *syntaxParser) rawValue() string {
+	var b strings.Builder
+	depth := 0
+	for !p.atStmtEnd() {
+		t := p.peek()
+		if t.space && depth == 0 {
+			break
+		}
+		p.next()
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		}
+		if t.kind == tokPercent {
+			b.WriteString(t.text + "%")
+		} else {
+			b.WriteString(t.text)
+		}
+	}
+	return b.String()
+}
+
+// binaryPrec задає пріоритет бінарних операторів.
+var binaryPrec = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+// expr розбирає вираз. Якщо arg, вираз є аргументом команди й пробіли поза дужками мають значення.
+func (p *syntaxParser) expr(arg bool) (expr, error) {
+	return p.binary(arg, 1)
+}
+
+func (p *syntaxParser) binary(arg bool, minPrec int) (expr, error) {
+	x, err := p.unary(arg)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		prec, ok := binaryPrec[op.text]
+		if op.kind != tokPunct || !ok || prec < minPrec {
+			return x, nil
+		}
+		if arg && op.space && !p.peekAt(1).space {
+			return x, nil // "x -1": новий аргумент з унарним мінусом
+		}
+		p.next()
+		y, err := p.binary(arg, prec+1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{op: op, x: x, y: y}
+	}
+}
+
+func (p *syntaxParser) unary(arg bool) (expr, error) {
+	if t := p.peek(); t.is("-") || t.is("+") || t.is("!") {
+		p.next()
+		if err := p.nest(t); err != nil {
+			return nil, err
+		}
+		defer p.unnest()
+		x, err := p.unary(arg)
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: t, x: x}, nil
+	}
+	return p.primary(arg)
+}
+
+func (p *syntaxParser) primary(arg bool) (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber, tokPercent, tokDuration, tokString, tokColor:
+		v, err := literalValue(t)
+		if err != nil {
+			return nil, errorAt(t, "%v", err)
+		}
+		return &literal{tok: t, val: v}, nil
+	case tokIdent:
+		if open := p.peek(); open.is("(") && !open.space {
+			p.next()
+			return p.call(t)
+		}
+		return &identExpr{tok: t}, nil
+	}
+	if t.is("(") {
+		if err := p.nest(t); err != nil {
+			return nil, err
+		}
+		defer p.unnest()
+		x, err := p.expr(false)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	return nil, errorAt(t, "expected a value, got %s", t)
+}
+
+func (p *syntaxParser) call(name token) (expr, error) {
+	if err := p.nest(name); err != nil {
+		return nil, err
+	}
+	defer p.unnest()
+	c := &callExpr{name: name}
+	if p.peek().is(")") {
+		p.next()
+		return c, nil
+	}
+	for {
+		x, err := p.expr(false)
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, x)
+		t := p.next()
+		if t.is(")") {
+			return c, nil
+		}
+		if !t.is(",") {
+			return nil, errorAt(t, "expected \",\" or \")\", got %s", t)
+		}
+	}
+}
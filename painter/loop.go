@@ -1,7 +1,14 @@
 package painter
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/exp/shiny/screen"
@@ -12,86 +19,357 @@ type Receiver interface {
 	Update(t screen.Texture)
 }
 
+// ErrStopped повертається при спробі додати операцію у цикл, який уже зупинено або зупиняється.
+var ErrStopped = errors.New("painter: loop is stopped")
+
 // Loop реалізує цикл подій для формування текстури отриманої через виконання операцій отриманих з внутрішньої черги.
+// Готові кадри передаються у Receiver в окремій горутині не частіше за FPS разів на секунду: кадри, які з'явились
+// за один інтервал, об'єднуються в один, а повільний Receiver не зупиняє виконання операцій. Поки у сцені є анімації,
+// цикл сам додає у чергу операції Tick з тією ж частотою.
 type Loop struct {
 	Receiver Receiver
+	FPS      int         // максимальна частота кадрів; 0 означає DefaultFPS
+	Size     image.Point // розмір полотна у пікселях; нульове значення означає DefaultSize
 
-	next screen.Texture // текстура, яка зараз формується
-	prev screen.Texture // текстура, яка була відправлення останнього разу у Receiver
+	screen    screen.Screen
+	canvas    image.Point    // поточний розмір полотна, змінюється лише горутиною циклу
+	next      screen.Texture // текстура, яка зараз формується
+	buf       screen.Buffer  // кадр у пам'яті для текстур без RGBA, див. bufferTexture; інакше nil
+	stale     bool           // next містить старий кадр і перед наступною операцією має бути перемальована
+	presenter *presenter
 
 	mq messageQueue
 
-	stop    chan struct{}
-	stopped chan struct{}
-	stopReq bool
+	sceneMu sync.RWMutex
+	scene   Scene // змінюється лише горутиною циклу під sceneMu
+
+	mu      sync.Mutex
+	stopped chan struct{} // закривається, коли горутина циклу завершила роботу
+
+	ticker      chan struct{} // закривається, щоб зупинити горутину таймера анімацій; nil, якщо таймер не запущено
+	lastTick    time.Time
+	tickPending atomic.Bool // операція tick уже в черзі, тож наступну додавати не треба
 }
 
-var size = image.Pt(400, 400)
+// DefaultSize — розмір полотна за замовчуванням.
+var DefaultSize = image.Pt(400, 400)
+
+// DefaultFPS — максимальна частота кадрів за замовчуванням.
+const DefaultFPS = 60
 
 // Start запускає цикл подій. Цей метод потрібно запустити до того, як викликати на ньому будь-які інші методи.
+// Повторні виклики ігноруються.
 func (l *Loop) Start(s screen.Screen) {
-	l.next, _ = s.NewTexture(size)
-	l.prev, _ = s.NewTexture(size)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped != nil {
+		return
+	}
+
+	l.screen = s
+	l.canvas = l.Size
+	if l.canvas.X <= 0 || l.canvas.Y <= 0 {
+		l.canvas = DefaultSize
+	}
+	// Чотири текстури: одна формується, одна чекає на відправлення, одна передається в Receiver, а ще одну Receiver
+	// показує, доки не отримає наступну.
+	var spare [3]screen.Texture
+	l.next, _ = s.NewTexture(l.canvas)
+	for i := range spare {
+		spare[i], _ = s.NewTexture(l.canvas)
+	}
+	l.presenter = newPresenter(l.Receiver, l.interval(), spare[:]...)
+	if _, ok := l.next.(interface{ RGBA() *image.RGBA }); !ok {
+		l.buf = l.newBuffer()
+	}
 
-	// TODO: стартувати цикл подій.
-	l.stop = make(chan struct{})
 	l.stopped = make(chan struct{})
 
 	go func() {
 		defer close(l.stopped)
-		ticker := time.NewTicker(10 * time.Millisecond)
-		defer ticker.Stop()
-
+		defer l.presenter.stop()
+		defer l.schedule(false)
 		for {
-			select {
-			case <-l.stop:
+			op := l.mq.pull()
+			if op == nil {
 				return
-			case <-ticker.C:
-				if l.mq.empty() {
-					continue
-				}
-				op := l.mq.pull()
-				if op == nil {
-					continue
+			}
+			if l.do(op) {
+				if l.buf != nil {
+					l.next.Upload(image.Point{}, l.buf, l.buf.Bounds())
 				}
-				if op.Do(l.next) {
-					l.Receiver.Update(l.next)
-					l.next, l.prev = l.prev, l.next
+				l.next = l.presenter.publish(l.next)
+				// Буфер уже містить останній кадр і повністю завантажується в наступну текстуру.
+				l.stale = l.buf == nil
+				if l.next.Size() != l.canvas {
+					// Текстура лишилась від попереднього розміру полотна.
+					l.next.Release()
+					l.next = l.newTexture()
 				}
 			}
+			l.schedule(len(l.scene.Tweens) > 0)
 		}
 	}()
 }
 
-// Post додає нову операцію у внутрішню чергу.
-func (l *Loop) Post(op Operation) {
-	l.mq.push(op)
+func (l *Loop) newTexture() screen.Texture {
+	t, err := l.screen.NewTexture(l.canvas)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (l *Loop) newBuffer() screen.Buffer {
+	b, err := l.screen.NewBuffer(l.canvas)
+	if err != nil {
+		panic(err)
+	}
+	return b
 }
 
-// StopAndWait сигналізує про необхідність завершити цикл та блокується до моменту його повної зупинки.
+// target повертає текстуру, на якій операції формують кадр.
+func (l *Loop) target() screen.Texture {
+	if l.buf == nil {
+		return l.next
+	}
+	return bufferTexture{Texture: l.next, buf: l.buf}
+}
+
+// bufferTexture — текстура для операцій, коли вміст текстур екрана недоступний через RGBA, як у віконного драйвера.
+// Кадр малюється в буфер buf, а цикл завантажує його в текстуру одним Upload, тож растеризатор не заливає текстуру
+// окремими смугами через Fill і може накладати напівпрозорі шари на вже намальоване.
+type bufferTexture struct {
+	screen.Texture
+	buf screen.Buffer
+}
+
+func (t bufferTexture) RGBA() *image.RGBA { return t.buf.RGBA() }
+
+func (t bufferTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	sr = sr.Intersect(src.Bounds())
+	draw.Draw(t.buf.RGBA(), image.Rectangle{Min: dp, Max: dp.Add(sr.Size())}, src.RGBA(), sr.Min, draw.Src)
+}
+
+func (t bufferTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(t.buf.RGBA(), dr, image.NewUniform(src), image.Point{}, op)
+}
+
+// Resize змінює розмір полотна. Сцена не залежить від розміру, тому одразу перемальовується на нових текстурах і
+// передається у Receiver.
+func (l *Loop) Resize(size image.Point) error {
+	if size.X <= 0 || size.Y <= 0 {
+		return fmt.Errorf("painter: invalid canvas size %v", size)
+	}
+	return l.Post(resize{l: l, size: size})
+}
+
+type resize struct {
+	l    *Loop
+	size image.Point
+}
+
+func (op resize) Do(t screen.Texture, s *Scene) bool {
+	l := op.l
+	if l.canvas == op.size {
+		return false
+	}
+	l.canvas = op.size
+	t.Release()
+	l.next = l.newTexture()
+	if l.buf != nil {
+		l.buf.Release()
+		l.buf = l.newBuffer()
+	}
+	render(s, l.target())
+	return true
+}
+
+func (l *Loop) interval() time.Duration {
+	fps := l.FPS
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	return time.Second / time.Duration(fps)
+}
+
+// Stats повертає лічильники кадрів. До виклику Start усі лічильники нульові.
+func (l *Loop) Stats() FrameStats {
+	l.mu.Lock()
+	p := l.presenter
+	l.mu.Unlock()
+	if p == nil {
+		return FrameStats{}
+	}
+	return p.frameStats()
+}
+
+// schedule запускає або зупиняє таймер анімацій. Викликається лише з горутини циклу.
+func (l *Loop) schedule(animating bool) {
+	switch {
+	case animating && l.ticker == nil:
+		l.ticker = make(chan struct{})
+		l.lastTick = time.Now()
+		go l.tick(l.ticker, l.interval())
+	case !animating && l.ticker != nil:
+		close(l.ticker)
+		l.ticker = nil
+	}
+}
+
+// tick додає у чергу операцію tick кожні interval, доки не закриють stop. Якщо попередня операція ще не виконана,
+// нова не додається, тож повільний цикл не накопичує черги кадрів.
+func (l *Loop) tick(stop <-chan struct{}, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if l.tickPending.CompareAndSwap(false, true) && l.Post(tick{l}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// tick просуває анімації на час, що минув з попереднього кадру.
+type tick struct{ l *Loop }
+
+func (op tick) Do(t screen.Texture, s *Scene) bool {
+	op.l.tickPending.Store(false)
+	now := time.Now()
+	dt := now.Sub(op.l.lastTick)
+	op.l.lastTick = now
+	return Tick{DT: dt}.Do(t, s)
+}
+
+func (l *Loop) do(op Operation) bool {
+	l.sceneMu.Lock()
+	defer l.sceneMu.Unlock()
+	t := l.target()
+	if l.stale {
+		render(&l.scene, t)
+		l.stale = false
+	}
+	return op.Do(t, &l.scene)
+}
+
+// Scene повертає копію поточного стану сцени. Метод можна викликати з будь-якої горутини, окрім самих операцій
+// всередині Do: вони отримують сцену безпосередньо.
+func (l *Loop) Scene() Scene {
+	l.sceneMu.RLock()
+	defer l.sceneMu.RUnlock()
+	return l.scene.Clone()
+}
+
+// Post додає нову операцію у внутрішню чергу. Метод можна викликати з будь-якої горутини. Після початку зупинки
+// циклу операції не приймаються і повертається ErrStopped.
+func (l *Loop) Post(op Operation) error {
+	if !l.mq.push(op) {
+		return ErrStopped
+	}
+	return nil
+}
+
+// StopAndWait сигналізує про необхідність завершити цикл та блокується до моменту його повної зупинки. Операції, які
+// вже є у черзі, виконуються перед зупинкою. Метод можна викликати повторно, а також до виклику Start.
 func (l *Loop) StopAndWait() {
-	close(l.stop)
-	<-l.stopped
+	_ = l.Shutdown(context.Background())
+}
+
+// Abort зупиняє цикл, відкидаючи операції, які ще не почали виконуватись, та чекає завершення поточної операції.
+func (l *Loop) Abort() {
+	l.mq.discard()
+	<-l.done()
 }
 
-// TODO: Реалізувати чергу подій.
+// Shutdown зупиняє цикл так само, як StopAndWait, але якщо контекст завершиться раніше, ніж черга буде вичерпана,
+// решта операцій відкидається, а метод повертає помилку контексту.
+func (l *Loop) Shutdown(ctx context.Context) error {
+	l.mq.close()
+	done := l.done()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		l.mq.discard()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// done повертає канал, який закривається після завершення горутини циклу. Якщо цикл не запускався, операції у черзі
+// виконати нікому, тому вони відкидаються.
+func (l *Loop) done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped == nil {
+		l.mq.discard()
+		l.stopped = make(chan struct{})
+		close(l.stopped)
+	}
+	return l.stopped
+}
+
+// messageQueue — блокуюча черга операцій. Горутина циклу подій чекає на умовній змінній, доки в черзі не з'явиться
+// операція або чергу не буде закрито, тому нічого не опитується у циклі.
 type messageQueue struct {
-	ops []Operation
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	ops      []Operation
+	closed   bool
+}
+
+func (mq *messageQueue) cond() *sync.Cond {
+	if mq.notEmpty == nil {
+		mq.notEmpty = sync.NewCond(&mq.mu)
+	}
+	return mq.notEmpty
 }
 
-func (mq *messageQueue) push(op Operation) {
+// push додає операцію у чергу. Якщо чергу закрито, повертає false.
+func (mq *messageQueue) push(op Operation) bool {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	if mq.closed {
+		return false
+	}
 	mq.ops = append(mq.ops, op)
+	mq.cond().Signal()
+	return true
 }
 
+// pull блокується до появи операції у черзі. Коли закриту чергу вичерпано, повертає nil.
 func (mq *messageQueue) pull() Operation {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	for len(mq.ops) == 0 && !mq.closed {
+		mq.cond().Wait()
+	}
 	if len(mq.ops) == 0 {
 		return nil
 	}
 	op := mq.ops[0]
+	mq.ops[0] = nil
 	mq.ops = mq.ops[1:]
 	return op
 }
 
-func (mq *messageQueue) empty() bool {
-	return len(mq.ops) == 0
+// close припиняє прийом нових операцій; ті, що вже в черзі, ще буде видано через pull.
+func (mq *messageQueue) close() {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	mq.closed = true
+	mq.cond().Broadcast()
+}
+
+// discard закриває чергу та відкидає операції, які в ній лишились.
+func (mq *messageQueue) discard() {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	mq.closed = true
+	mq.ops = nil
+	mq.cond().Broadcast()
 }
@@ -1,13 +1,17 @@
 package painter
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"image/draw"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/DmytroHalai/kpi-3/ui/headless"
 	"golang.org/x/exp/shiny/screen"
 )
 
@@ -16,11 +20,10 @@ var testSize = image.Pt(400, 400)
 func TestLoop_UpdatesTexture(t *testing.T) {
 	var l Loop
 	var tr testReceiver
-	scene := &Scene{}
 	l.Receiver = &tr
 	l.Start(mockScreen{})
 
-	l.Post(WhiteFill(scene))
+	l.Post(WhiteFill())
 	l.Post(UpdateOp)
 
 	time.Sleep(50 * time.Millisecond)
@@ -33,7 +36,7 @@ func TestLoop_UpdatesTexture(t *testing.T) {
 	if !ok {
 		t.Fatal("Unexpected texture type:", tr.lastTexture)
 	}
-	if len(mt.Colors) == 0 || mt.Colors[0] != color.White {
+	if len(mt.Colors) == 0 || !sameColor(mt.Colors[0], color.White) {
 		t.Errorf("Expected white fill, got: %+v", mt.Colors)
 	}
 }
@@ -41,20 +44,20 @@ func TestLoop_UpdatesTexture(t *testing.T) {
 func TestLoop_ProcessesMultipleOps(t *testing.T) {
 	var l Loop
 	var tr testReceiver
-	scene := &Scene{}
 	l.Receiver = &tr
 	l.Start(mockScreen{})
 
-	l.Post(WhiteFill(scene))
-	l.Post(GreenFill(scene))
+	l.Post(WhiteFill())
+	l.Post(GreenFill())
 	l.Post(UpdateOp)
 
 	time.Sleep(50 * time.Millisecond)
 	l.StopAndWait()
 
 	mt := tr.lastTexture.(*mockTexture)
-	if len(mt.Colors) < 2 {
-		t.Errorf("Expected at least 2 fills, got: %+v", mt.Colors)
+	// Обидві заливки потрапляють в один кадр, тож завантажується вже зелений.
+	if len(mt.Colors) == 0 || !sameColor(mt.Colors[len(mt.Colors)-1], GreenFill().(Fill).Color) {
+		t.Errorf("Expected a green frame, got: %+v", mt.Colors)
 	}
 }
 
@@ -107,6 +110,361 @@ func TestLoop_StopBlocksUntilDone(t *testing.T) {
 	}
 }
 
+func TestLoop_ConcurrentPost(t *testing.T) {
+	const goroutines, perGoroutine = 8, 200
+
+	var l Loop
+	var tr testReceiver
+	l.Receiver = &tr
+	l.Start(mockScreen{})
+
+	var (
+		count int
+		wg    sync.WaitGroup
+	)
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perGoroutine {
+				l.Post(OperationFunc(func(screen.Texture) { count++ }))
+			}
+		}()
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	l.Post(OperationFunc(func(screen.Texture) { close(done) }))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("operations were not processed in time")
+	}
+	l.StopAndWait()
+
+	if count != goroutines*perGoroutine {
+		t.Errorf("expected %d operations, got %d", goroutines*perGoroutine, count)
+	}
+}
+
+func TestLoop_StopAndWaitDrainsQueue(t *testing.T) {
+	var l Loop
+	var tr testReceiver
+	l.Receiver = &tr
+
+	count := 0
+	release := make(chan struct{})
+	l.Start(mockScreen{})
+	l.Post(OperationFunc(func(screen.Texture) { <-release }))
+	for range 10 {
+		l.Post(OperationFunc(func(screen.Texture) { count++ }))
+	}
+	close(release)
+	l.StopAndWait()
+
+	if count != 10 {
+		t.Errorf("expected queued operations to run before stop, got %d of 10", count)
+	}
+	if err := l.Post(UpdateOp); err != ErrStopped {
+		t.Errorf("expected ErrStopped after stop, got %v", err)
+	}
+	l.StopAndWait() // Повторний виклик не має панікувати чи блокуватись.
+}
+
+func TestLoop_AbortDiscardsQueue(t *testing.T) {
+	var l Loop
+	var tr testReceiver
+	l.Receiver = &tr
+
+	count := 0
+	started, release := make(chan struct{}), make(chan struct{})
+	l.Start(mockScreen{})
+	l.Post(OperationFunc(func(screen.Texture) {
+		close(started)
+		<-release
+	}))
+	for range 10 {
+		l.Post(OperationFunc(func(screen.Texture) { count++ }))
+	}
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	l.Abort()
+
+	if count != 0 {
+		t.Errorf("expected queued operations to be discarded, %d were executed", count)
+	}
+}
+
+func TestLoop_ShutdownHonorsContext(t *testing.T) {
+	var l Loop
+	var tr testReceiver
+	l.Receiver = &tr
+
+	count := 0
+	release := make(chan struct{})
+	l.Start(mockScreen{})
+	l.Post(OperationFunc(func(screen.Texture) { <-release }))
+	l.Post(OperationFunc(func(screen.Texture) { count++ }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond) // Даємо Shutdown відкинути чергу.
+		close(release)
+	}()
+	if err := l.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if count != 0 {
+		t.Error("operation queued after the deadline was executed")
+	}
+}
+
+func TestLoop_StopBeforeStart(t *testing.T) {
+	var l Loop
+	l.Post(UpdateOp)
+	l.StopAndWait()
+	l.Abort()
+	if err := l.Post(UpdateOp); err != ErrStopped {
+		t.Errorf("expected ErrStopped, got %v", err)
+	}
+}
+
+func TestLoop_SceneSnapshot(t *testing.T) {
+	var l Loop
+	var tr testReceiver
+	l.Receiver = &tr
+	l.Start(mockScreen{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			s := l.Scene()
+			for i := range s.Shapes {
+				s.Shapes[i].X = -1 // Зміна копії не має впливати на сцену циклу.
+			}
+		}
+	}()
+	for i := range 100 {
+		l.Post(ShapeOp(float64(i)/100, float64(i)/100))
+	}
+	<-done
+	l.StopAndWait()
+
+	s := l.Scene()
+	if len(s.Shapes) != 100 {
+		t.Fatalf("expected 100 shapes, got %d", len(s.Shapes))
+	}
+	for i, shape := range s.Shapes {
+		if v := float64(i) / 100; shape.X != v || shape.Y != v {
+			t.Errorf("shape %d was changed through a snapshot: %+v", i, shape)
+		}
+	}
+}
+
+func TestLoop_UploadsFrames(t *testing.T) {
+	var (
+		l   Loop
+		tr  testReceiver
+		scr driverScreen
+	)
+	l.Receiver = &tr
+	l.Start(&scr)
+	translucent := []color.Color{color.NRGBA{R: 255, A: 128}, color.Black}
+	ops := []Operation{
+		FillPaint{Paint: Paint{Kind: "linear", Params: []float64{0, 0, 1, 0}, Colors: []color.Color{color.White, color.Black}}},
+		AddRect{ID: "r", X1: 0.2, Y1: 0.2, X2: 0.6, Y2: 0.4},
+		FillPaint{ID: "r", Paint: Paint{Kind: "radial", Params: []float64{0.3, 0.3, 0.4}, Colors: translucent}},
+		AddPrimitive{Kind: "circle", Params: []float64{0.7, 0.7, 0.2}, Fill: color.NRGBA{B: 255, A: 200}, Stroke: color.White},
+		AddText{X: 0.1, Y: 0.8, Size: 0.1, Text: "frame"},
+	}
+	l.Post(append(OperationList{}, append(ops, UpdateOp)...))
+	l.StopAndWait()
+
+	// Кадр малюється в буфер і завантажується в текстуру драйвера одним Upload.
+	if f, u := scr.fills.Load(), scr.uploads.Load(); f != 0 || u != int64(l.Stats().Rendered) {
+		t.Errorf("expected one upload per frame and no fills, got %d fills and %d uploads", f, u)
+	}
+	tx, _ := headless.Screen{}.NewTexture(testSize)
+	renderPrimitives(tx, ops...)
+	want := tx.(*headless.Texture).RGBA()
+	got := tr.lastTexture.(driverTexture).Texture.(*headless.Texture).RGBA()
+	if !reflect.DeepEqual(got.Pix, want.Pix) {
+		t.Error("uploaded frame differs from the scene rendered directly")
+	}
+}
+
+// driverScreen створює текстури без RGBA, як віконний драйвер, і рахує виклики Fill та Upload.
+type driverScreen struct {
+	headless.Screen
+	fills, uploads atomic.Int64
+}
+
+func (s *driverScreen) NewTexture(size image.Point) (screen.Texture, error) {
+	t, err := s.Screen.NewTexture(size)
+	return driverTexture{Texture: t, s: s}, err
+}
+
+type driverTexture struct {
+	screen.Texture
+	s *driverScreen
+}
+
+func (t driverTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	t.s.uploads.Add(1)
+	t.Texture.Upload(dp, src, sr)
+}
+
+func (t driverTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	t.s.fills.Add(1)
+	t.Texture.Fill(dr, src, op)
+}
+
+func TestLoop_CanvasSize(t *testing.T) {
+	var frames headless.Recorder
+	l := Loop{Receiver: &frames, Size: image.Pt(200, 100)}
+	l.Start(headless.Screen{})
+
+	l.Post(OperationList{WhiteFill(), AddRect{X1: 0.5, X2: 1, Y2: 1}, UpdateOp})
+	if err := l.Resize(image.Pt(300, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Resize(image.Point{}); err == nil {
+		t.Error("expected an error for an empty canvas")
+	}
+	l.Post(UpdateOp) // Текстура попереднього розміру, повернута з Receiver, має бути замінена.
+	l.StopAndWait()
+
+	img := frames.Frame()
+	if img == nil || img.Rect.Size() != image.Pt(300, 300) {
+		t.Fatalf("expected a 300x300 frame, got %v", img)
+	}
+	if img.RGBAAt(149, 150) != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) || img.RGBAAt(150, 150) != (color.RGBA{A: 255}) {
+		t.Error("scene was not redrawn at the new size")
+	}
+}
+
+// blockingReceiver блокує Update, доки не закриють release.
+type blockingReceiver struct {
+	release chan struct{}
+	frames  atomic.Int32
+}
+
+func (r *blockingReceiver) Update(screen.Texture) {
+	<-r.release
+	r.frames.Add(1)
+}
+
+// displayReceiver, як вікно, показує отриману текстуру, доки не отримає наступну.
+type displayReceiver struct {
+	mu    sync.Mutex
+	shown screen.Texture
+}
+
+func (r *displayReceiver) Update(t screen.Texture) {
+	r.mu.Lock()
+	r.shown = t
+	r.mu.Unlock()
+}
+
+func TestLoop_KeepsShownTexture(t *testing.T) {
+	r := &displayReceiver{}
+	l := Loop{Receiver: r, FPS: 1000}
+	l.Start(headless.Screen{})
+
+	var overwritten atomic.Int32
+	check := OperationFunc(func(t screen.Texture) {
+		r.mu.Lock()
+		if t == r.shown {
+			overwritten.Add(1)
+		}
+		r.mu.Unlock()
+	})
+	for range 300 {
+		l.Post(OperationList{check, UpdateOp})
+		time.Sleep(100 * time.Microsecond)
+	}
+	l.StopAndWait()
+	if n := overwritten.Load(); n != 0 {
+		t.Errorf("loop drew %d frames on the texture shown by the receiver", n)
+	}
+}
+
+func TestLoop_SlowReceiverDoesNotBlockOps(t *testing.T) {
+	r := &blockingReceiver{release: make(chan struct{})}
+	l := Loop{Receiver: r, FPS: 1000}
+	l.Start(mockScreen{})
+
+	for range 20 {
+		l.Post(UpdateOp)
+	}
+	done := make(chan struct{})
+	l.Post(OperationFunc(func(screen.Texture) { close(done) }))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("operations are blocked by the receiver")
+	}
+	close(r.release)
+	l.StopAndWait()
+
+	st := l.Stats()
+	if st.Rendered != 20 || st.Delivered != uint64(r.frames.Load()) {
+		t.Errorf("unexpected stats: %+v, receiver got %d frames", st, r.frames.Load())
+	}
+	if st.Delivered > 3 || st.Dropped+st.Coalesced != st.Rendered-st.Delivered {
+		t.Errorf("expected frames to be dropped while the receiver is busy: %+v", st)
+	}
+}
+
+func TestLoop_FrameRateLimit(t *testing.T) {
+	var r countingReceiver
+	l := Loop{Receiver: &r, FPS: 10}
+	l.Start(mockScreen{})
+
+	l.Post(UpdateOp)
+	time.Sleep(20 * time.Millisecond) // Перший кадр передається одразу, далі діє інтервал у 100 мс.
+	for range 10 {
+		l.Post(UpdateOp)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := r.frames.Load(); n != 1 {
+		t.Errorf("expected 1 frame within the first interval, got %d", n)
+	}
+	l.StopAndWait()
+
+	if st := l.Stats(); st.Delivered != 2 || st.Coalesced != 9 {
+		t.Errorf("expected the burst to be coalesced into one frame: %+v", st)
+	}
+}
+
+func BenchmarkLoop_Throughput(b *testing.B) {
+	var l Loop
+	var tr testReceiver
+	l.Receiver = &tr
+	l.Start(mockScreen{})
+
+	noop := OperationFunc(func(screen.Texture) {})
+	done := make(chan struct{})
+	b.ResetTimer()
+	start := time.Now()
+	for range b.N {
+		l.Post(noop)
+	}
+	l.Post(OperationFunc(func(screen.Texture) { close(done) }))
+	<-done
+	elapsed := time.Since(start)
+	b.StopTimer()
+	l.StopAndWait()
+
+	b.ReportMetric(float64(b.N)*float64(10*time.Millisecond)/float64(elapsed), "ops/10ms")
+}
+
 type testReceiver struct {
 	lastTexture screen.Texture
 }
@@ -118,7 +476,7 @@ func (tr *testReceiver) Update(t screen.Texture) {
 type mockScreen struct{}
 
 func (m mockScreen) NewBuffer(size image.Point) (screen.Buffer, error) {
-	panic("not implemented")
+	return headless.Screen{}.NewBuffer(size)
 }
 
 func (m mockScreen) NewTexture(size image.Point) (screen.Texture, error) {
@@ -129,6 +487,8 @@ func (m mockScreen) NewWindow(opts *screen.NewWindowOptions) (screen.Window, err
 	panic("not implemented")
 }
 
+// mockTexture, як текстура віконного драйвера, не надає RGBA. Colors містить кольори заливок і лівого верхнього
+// пікселя завантажених кадрів.
 type mockTexture struct {
 	Colors []color.Color
 }
@@ -141,7 +501,9 @@ func (m *mockTexture) Bounds() image.Rectangle {
 	return image.Rectangle{Max: m.Size()}
 }
 
-func (m *mockTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {}
+func (m *mockTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	m.Colors = append(m.Colors, src.RGBA().At(sr.Min.X, sr.Min.Y))
+}
 
 func (m *mockTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
 	m.Colors = append(m.Colors, src)
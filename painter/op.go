@@ -1,44 +1,24 @@
 package painter
 
 import (
-	"image"
 	"image/color"
 
-	"github.com/DmytroHalai/kpi-3/ui"
-
 	"golang.org/x/exp/shiny/screen"
 )
 
 // Operation змінює вхідну текстуру.
 type Operation interface {
-	// Do виконує зміну операції, повертаючи true, якщо текстура вважається готовою для відображення.
-	Do(t screen.Texture) (ready bool)
-}
-
-type Shape struct {
-	X int
-	Y int
-}
-
-type Rectangle struct {
-	X1 int
-	Y1 int
-	X2 int
-	Y2 int
-}
-
-type Scene struct {
-	BgColor color.Color
-	Rect    *Rectangle
-	Shapes  []Shape
+	// Do виконує зміну операції, повертаючи true, якщо текстура вважається готовою для відображення. Сцена належить
+	// циклу подій, тому операції змінюють її лише всередині Do.
+	Do(t screen.Texture, s *Scene) (ready bool)
 }
 
 // OperationList групує список операції в одну.
 type OperationList []Operation
 
-func (ol OperationList) Do(t screen.Texture) (ready bool) {
+func (ol OperationList) Do(t screen.Texture, s *Scene) (ready bool) {
 	for _, o := range ol {
-		ready = o.Do(t) || ready
+		ready = o.Do(t, s) || ready
 	}
 	return
 }
@@ -48,79 +28,252 @@ var UpdateOp = updateOp{}
 
 type updateOp struct{}
 
-func (op updateOp) Do(t screen.Texture) bool { return true }
+func (op updateOp) Do(t screen.Texture, s *Scene) bool { return true }
 
 // OperationFunc використовується для перетворення функції оновлення текстури в Operation.
 type OperationFunc func(t screen.Texture)
 
-func (f OperationFunc) Do(t screen.Texture) bool {
+func (f OperationFunc) Do(t screen.Texture, s *Scene) bool {
 	f(t)
 	return false
 }
 
-func render(scene *Scene, t screen.Texture) {
-	bgColor := scene.BgColor
-	if bgColor == nil {
-		bgColor = color.RGBA{G: 128, A: 255}
+// Fill заливає фон сцени суцільним кольором, скасовуючи заливку FillPaint.
+type Fill struct {
+	Color color.Color
+}
+
+func (op Fill) Do(t screen.Texture, s *Scene) bool {
+	s.BgColor = op.Color
+	s.BgPaint = nil
+	render(s, t)
+	return false
+}
+
+func WhiteFill() Operation {
+	return Fill{Color: color.White}
+}
+
+func GreenFill() Operation {
+	return Fill{Color: color.RGBA{G: 128, A: 255}}
+}
+
+// AddRect додає прямокутник на фон сцени поверх інших елементів. Якщо ID порожній, прямокутнику призначається
+// новий ідентифікатор; якщо елемент з таким ID вже існує, прямокутник займає його місце та z-індекс, а без Parent —
+// і його групу. Parent, який не є групою сцени, означає корінь сцени.
+type AddRect Rectangle
+
+func (op AddRect) Do(t screen.Texture, s *Scene) bool {
+	rect := Rectangle(op)
+	if rect.ID == "" {
+		rect.ID = s.newID("r")
+	}
+	if rect.Parent == "" {
+		if p := s.parent(rect.ID); p != nil {
+			rect.Parent = *p
+		}
 	}
-	t.Fill(t.Bounds(), bgColor, screen.Src)
-	rect := scene.Rect
-	if rect != nil {
-		t.Fill(image.Rect(rect.X1, rect.Y1, rect.X2, rect.Y2), color.Black, screen.Src)
+	rect.Parent = s.validParent(rect.ID, rect.Parent)
+	if z := s.z(rect.ID); z != nil {
+		rect.Z = *z
+	} else {
+		rect.Z = s.topZ()
 	}
-	for _, shape := range scene.Shapes {
-		ui.DrawTShape(t, shape.X, shape.Y, t.Bounds(), color.RGBA{R: 255, G: 255, A: 255})
+	if i := s.Rect(rect.ID); i >= 0 {
+		s.Rects[i] = rect
+	} else {
+		s.remove(rect.ID)
+		s.Rects = append(s.Rects, rect)
 	}
+	render(s, t)
+	return false
 }
 
-func WhiteFill(scene *Scene) Operation {
-	return OperationFunc(func(t screen.Texture) {
-		scene.BgColor = color.White
-		render(scene, t)
-	})
+func BgRectOp(x1, y1, x2, y2 float64) Operation {
+	return AddRect{X1: x1, Y1: y1, X2: x2, Y2: y2}
 }
 
-func GreenFill(scene *Scene) Operation {
-	return OperationFunc(func(t screen.Texture) {
-		scene.BgColor = color.RGBA{G: 128, A: 255}
-		render(scene, t)
-	})
+// ClearRects видаляє всі прямокутники сцени.
+type ClearRects struct{}
+
+func (op ClearRects) Do(t screen.Texture, s *Scene) bool {
+	s.Rects = nil
+	render(s, t)
+	return false
 }
 
-func BgRectOp(scene *Scene, x1, y1, x2, y2 int) Operation {
-	return OperationFunc(func(t screen.Texture) {
-		scene.Rect = &Rectangle{x1, y1, x2, y2}
-		render(scene, t)
-	})
+// AddShape додає фігуру у сцену поверх інших елементів. Якщо ID порожній, фігурі призначається новий
+// ідентифікатор; якщо елемент з таким ID вже існує, фігура займає його місце та z-індекс, а без Parent — і його
+// групу. Parent, який не є групою сцени, означає корінь сцени.
+type AddShape Shape
+
+func (op AddShape) Do(t screen.Texture, s *Scene) bool {
+	shape := Shape(op)
+	if shape.ID == "" {
+		shape.ID = s.newID("f")
+	}
+	if shape.Parent == "" {
+		if p := s.parent(shape.ID); p != nil {
+			shape.Parent = *p
+		}
+	}
+	shape.Parent = s.validParent(shape.ID, shape.Parent)
+	if z := s.z(shape.ID); z != nil {
+		shape.Z = *z
+	} else {
+		shape.Z = s.topZ()
+	}
+	if i := s.Shape(shape.ID); i >= 0 {
+		s.Shapes[i] = shape
+	} else {
+		s.remove(shape.ID)
+		s.Shapes = append(s.Shapes, shape)
+	}
+	render(s, t)
+	return false
 }
 
-func ShapeOp(scene *Scene, x1, x2 int) Operation {
-	return OperationFunc(func(t screen.Texture) {
-		scene.Shapes = append(scene.Shapes, Shape{x1, x2})
-		render(scene, t)
-	})
+func ShapeOp(x, y float64) Operation {
+	return AddShape{X: x, Y: y}
 }
 
-func MoveOp(scene *Scene, x, y int) Operation {
-	return OperationFunc(func(t screen.Texture) {
-		if len(scene.Shapes) == 0 {
-			ShapeOp(scene, x, y)
-			render(scene, t)
+// MoveShapes переносить фігуру, примітив, напис або зображення з указаним ID у задану точку або, якщо Relative,
+// зсуває на (X, Y). Без ID операція застосовується до всіх фігур; якщо фігур немає, при абсолютному переміщенні у точці
+// з'являється нова. Анімація фігури, яку переміщено, скасовується.
+type MoveShapes struct {
+	ID       string
+	X        float64
+	Y        float64
+	Relative bool
+}
+
+func (op MoveShapes) Do(t screen.Texture, s *Scene) bool {
+	if op.ID == "" && len(s.Shapes) == 0 && !op.Relative {
+		s.Shapes = append(s.Shapes, Shape{ID: s.newID("f"), Z: s.topZ()})
+	}
+	for i := range s.Shapes {
+		shape := &s.Shapes[i]
+		if op.ID != "" && shape.ID != op.ID {
+			continue
 		}
-		newShapes := make([]Shape, len(scene.Shapes))
-		for i := range scene.Shapes {
-			newShapes[i] = Shape{x, y}
+		s.stopTween(shape.ID)
+		if op.Relative {
+			shape.X += op.X
+			shape.Y += op.Y
+		} else {
+			shape.X, shape.Y = op.X, op.Y
+		}
+	}
+	if i := s.Text(op.ID); op.ID != "" && i >= 0 {
+		if text := &s.Texts[i]; op.Relative {
+			text.X += op.X
+			text.Y += op.Y
+		} else {
+			text.X, text.Y = op.X, op.Y
+		}
+	}
+	if i := s.Image(op.ID); op.ID != "" && i >= 0 {
+		if im := &s.Images[i]; op.Relative {
+			im.X += op.X
+			im.Y += op.Y
+		} else {
+			im.X, im.Y = op.X, op.Y
+		}
+	}
+	// Примітив без відносного зсуву переміщується так, щоб центр його контурів опинився в точці (X, Y).
+	if i := s.Primitive(op.ID); op.ID != "" && i >= 0 {
+		if p := &s.Primitives[i]; op.Relative {
+			p.X += op.X
+			p.Y += op.Y
+		} else if x, y, ok := p.center(); ok {
+			p.X += op.X - x
+			p.Y += op.Y - y
 		}
-		scene.Shapes = newShapes
-		render(scene, t)
-	})
-}
-
-func ResetOp(scene *Scene) Operation {
-	return OperationFunc(func(t screen.Texture) {
-		scene.BgColor = color.Black
-		scene.Rect = nil
-		scene.Shapes = nil
-		render(scene, t)
-	})
+	}
+	render(s, t)
+	return false
+}
+
+func MoveOp(x, y float64) Operation {
+	return MoveShapes{X: x, Y: y}
+}
+
+// Delete видаляє елемент сцени з указаним ID.
+type Delete struct {
+	ID string
+}
+
+func (op Delete) Do(t screen.Texture, s *Scene) bool {
+	s.remove(op.ID)
+	render(s, t)
+	return false
+}
+
+// Recolor змінює колір фігури, прямокутника, напису або примітиву з указаним ID. Прямокутник втрачає заливку
+// FillPaint, а у примітиву змінюється колір заливки або, якщо він лише обведений, колір контуру.
+type Recolor struct {
+	ID    string
+	Color color.Color
+}
+
+func (op Recolor) Do(t screen.Texture, s *Scene) bool {
+	if i := s.Shape(op.ID); i >= 0 {
+		s.Shapes[i].Color = op.Color
+	}
+	if i := s.Rect(op.ID); i >= 0 {
+		s.Rects[i].Color = op.Color
+		s.Rects[i].Paint = nil
+	}
+	if i := s.Text(op.ID); i >= 0 {
+		s.Texts[i].Color = op.Color
+	}
+	if i := s.Primitive(op.ID); i >= 0 {
+		if p := &s.Primitives[i]; p.Fill == nil && p.Stroke != nil {
+			p.Stroke = op.Color
+		} else {
+			p.Fill = op.Color
+		}
+	}
+	render(s, t)
+	return false
+}
+
+// Raise переносить елемент з указаним ID поверх усіх інших.
+type Raise struct {
+	ID string
+}
+
+func (op Raise) Do(t screen.Texture, s *Scene) bool {
+	if z := s.z(op.ID); z != nil {
+		*z = s.topZ()
+	}
+	render(s, t)
+	return false
+}
+
+// Lower переносить елемент з указаним ID під усі інші.
+type Lower struct {
+	ID string
+}
+
+func (op Lower) Do(t screen.Texture, s *Scene) bool {
+	if z := s.z(op.ID); z != nil {
+		lo, _ := s.zRange()
+		*z = lo - 1
+	}
+	render(s, t)
+	return false
+}
+
+// Reset очищує сцену. Історія змін зберігається, тож очищення можна скасувати.
+type Reset struct{}
+
+func (op Reset) Do(t screen.Texture, s *Scene) bool {
+	*s = Scene{BgColor: color.Black, history: s.history}
+	render(s, t)
+	return false
+}
+
+func ResetOp() Operation {
+	return Reset{}
 }
@@ -0,0 +1,159 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+)
+
+func applyOps(s *Scene, ops ...Operation) {
+	OperationList(ops).Do(new(mockTexture), s)
+}
+
+func TestShapes_AddressedByID(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddShape{ID: "a", X: 10, Y: 10},
+		ShapeOp(20, 20),
+		MoveShapes{ID: "a", X: 50, Y: 60},
+		MoveShapes{ID: "f1", X: 5, Y: -5, Relative: true},
+		Recolor{ID: "a", Color: color.White},
+	)
+
+	if len(s.Shapes) != 2 {
+		t.Fatalf("expected 2 shapes, got %+v", s.Shapes)
+	}
+	a, f1 := s.Shapes[s.Shape("a")], s.Shapes[s.Shape("f1")]
+	if a.X != 50 || a.Y != 60 || a.Color != color.White {
+		t.Errorf("unexpected shape a: %+v", a)
+	}
+	if f1.X != 25 || f1.Y != 15 {
+		t.Errorf("unexpected shape f1: %+v", f1)
+	}
+
+	applyOps(&s, Delete{ID: "a"})
+	if len(s.Shapes) != 1 || s.Shape("a") >= 0 {
+		t.Errorf("shape a was not deleted: %+v", s.Shapes)
+	}
+}
+
+func TestShapes_ReplaceAndAutoID(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddShape{ID: "f2", X: 1, Y: 1},
+		ShapeOp(2, 2),
+		ShapeOp(3, 3),
+		AddShape{ID: "f2", X: 4, Y: 4},
+	)
+
+	ids := []string{}
+	for _, shape := range s.Shapes {
+		ids = append(ids, shape.ID)
+	}
+	if len(ids) != 3 || ids[0] != "f2" || ids[1] != "f1" || ids[2] != "f3" {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+	if s.Shapes[0].X != 4 {
+		t.Errorf("shape f2 was not replaced: %+v", s.Shapes[0])
+	}
+}
+
+func TestRects_Layering(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddRect{ID: "back", X1: 0, Y1: 0, X2: 100, Y2: 100, Color: color.White},
+		AddShape{ID: "a", X: 50, Y: 50},
+		AddRect{ID: "front", X1: 40, Y1: 40, X2: 60, Y2: 60},
+		BgRectOp(0, 0, 1, 1),
+	)
+	if len(s.Rects) != 3 {
+		t.Fatalf("expected 3 rects, got %+v", s.Rects)
+	}
+
+	order := func() []string {
+		var ids []string
+		for _, r := range s.Rects {
+			ids = append(ids, fmt.Sprintf("%s:%d", r.ID, r.Z))
+		}
+		return ids
+	}
+	applyOps(&s, Raise{ID: "back"}, Lower{ID: "a"})
+	lo, hi := s.zRange()
+	if back, a := s.Rects[s.Rect("back")].Z, s.Shapes[s.Shape("a")].Z; back != hi || a != lo || lo == hi {
+		t.Errorf("unexpected z after raise/lower: rects %v, shape a z=%d", order(), a)
+	}
+
+	applyOps(&s, AddRect{ID: "a", X1: 1, Y1: 1, X2: 2, Y2: 2})
+	if s.Shape("a") >= 0 || s.Rects[s.Rect("a")].Z != lo {
+		t.Errorf("rect did not replace shape a: %v", order())
+	}
+
+	applyOps(&s, Delete{ID: "front"}, ClearRects{})
+	if len(s.Rects) != 0 {
+		t.Errorf("expected no rects, got %v", order())
+	}
+}
+
+func TestRender_ZOrder(t *testing.T) {
+	s := Scene{BgColor: color.White}
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(400, 400))
+	img := tx.(*headless.Texture).RGBA()
+	red := color.RGBA{R: 255, A: 255}
+
+	AddShape{ID: "a", X: 0.5, Y: 0.5}.Do(tx, &s)
+	AddRect{ID: "r", X1: 0.475, Y1: 0.475, X2: 0.525, Y2: 0.525, Color: red}.Do(tx, &s)
+	if got := img.RGBAAt(200, 200); got != red {
+		t.Errorf("expected rect on top of the shape, got %v", got)
+	}
+
+	Raise{ID: "a"}.Do(tx, &s)
+	if got := img.RGBAAt(200, 200); got != DefaultShapeColor {
+		t.Errorf("expected shape on top of the rect, got %v", got)
+	}
+
+	AddRect{ID: "r", X1: 0, Y1: 0, X2: 0.25, Y2: 0.25, Color: red, Outline: true}.Do(tx, &s)
+	if got := img.RGBAAt(50, 50); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("expected outline rect to keep its inside, got %v", got)
+	}
+	if got := img.RGBAAt(0, 50); got != red {
+		t.Errorf("expected outline on the rect border, got %v", got)
+	}
+}
+
+func TestRender_Alpha(t *testing.T) {
+	s := Scene{BgColor: color.White}
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(400, 400))
+	img := tx.(*headless.Texture).RGBA()
+
+	AddRect{X1: 0, Y1: 0, X2: 0.25, Y2: 0.25, Color: color.NRGBA{A: 128}}.Do(tx, &s)
+	if got := img.RGBAAt(50, 50); got.R != 127 || got.A != 255 {
+		t.Errorf("expected half-transparent black over white, got %v", got)
+	}
+
+	AddShape{X: 0.5, Y: 0.5, Color: color.NRGBA{R: 255, A: 128}}.Do(tx, &s)
+	top, stem := img.RGBAAt(200, 140), img.RGBAAt(200, 250)
+	if top != stem || top.G != 127 {
+		t.Errorf("expected T-shape to be blended evenly, got top %v and stem %v", top, stem)
+	}
+}
+
+func TestRender_CanvasSize(t *testing.T) {
+	s := Scene{BgColor: color.White}
+	red := color.RGBA{R: 255, A: 255}
+	applyOps(&s, AddRect{X1: 0.5, Y1: 0.5, X2: 1, Y2: 1, Color: red})
+
+	for _, size := range []image.Point{image.Pt(100, 100), image.Pt(800, 200)} {
+		tx, _ := headless.Screen{}.NewTexture(size)
+		img := tx.(*headless.Texture).RGBA()
+		render(&s, tx)
+		if got := img.RGBAAt(size.X/2, size.Y/2); got != red {
+			t.Errorf("%v: expected the rect to start in the middle, got %v", size, got)
+		}
+		if got := img.RGBAAt(size.X/2-1, size.Y/2-1); got == red {
+			t.Errorf("%v: expected the rect to scale with the canvas", size)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+// Paint — заливка фону або прямокутника градієнтом чи візерунком. Координати в Params задаються в частках розмірів
+// області, яку заливають, а довжини (радіус, розмір клітинки, ширина смуги) — в частках її меншої сторони. Види:
+//
+//	linear x1 y1 x2 y2      лінійний градієнт від точки (x1, y1) до (x2, y2)
+//	radial x y r            радіальний градієнт з центром (x, y) та радіусом r
+//	checker size            шахівниця з квадратних клітинок
+//	stripes width [angle]   смуги, перпендикулярні напрямку angle у градусах; 0 означає вертикальні смуги
+//
+// Кольори градієнта рівномірно розподіляються від його початку до кінця, а клітинки та смуги по черзі беруть
+// кольори Colors. Заливка растеризується процесором, адже screen.Texture вміє заливати лише одним кольором.
+type Paint struct {
+	Kind   string
+	Params []float64
+	Colors []color.Color // щонайменше два кольори
+}
+
+// paintKinds — допустима кількість параметрів кожного виду заливки.
+var paintKinds = map[string][2]int{
+	"linear":  {4, 4},
+	"radial":  {3, 3},
+	"checker": {1, 1},
+	"stripes": {1, 2},
+}
+
+// PaintKinds повертає назви видів заливки за абеткою.
+func PaintKinds() []string {
+	names := make([]string, 0, len(paintKinds))
+	for name := range paintKinds {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Validate перевіряє вид заливки, її параметри та кольори.
+func (p *Paint) Validate() error {
+	n, ok := paintKinds[p.Kind]
+	if !ok {
+		return fmt.Errorf("unknown paint kind %q", p.Kind)
+	}
+	if err := need(p.Kind, p.Params, n[0], n[1]); err != nil {
+		return err
+	}
+	for _, v := range p.Params {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%s parameters must be finite numbers", p.Kind)
+		}
+	}
+	if size := p.size(); !(size > 0) {
+		return fmt.Errorf("%s size must be positive, got %g", p.Kind, size)
+	}
+	if len(p.Colors) < 2 || slices.Contains(p.Colors, nil) {
+		return fmt.Errorf("%s requires at least 2 colors", p.Kind)
+	}
+	return nil
+}
+
+// size повертає довжину, яка має бути додатною: радіус, розмір клітинки чи ширину смуги. Лінійний градієнт такої
+// довжини не має, тож для нього повертається 1.
+func (p *Paint) size() float64 {
+	switch p.Kind {
+	case "radial":
+		return p.Params[2]
+	case "checker", "stripes":
+		return p.Params[0]
+	}
+	return 1
+}
+
+// clone повертає копію заливки, яка не ділить з нею слайси.
+func (p *Paint) clone() *Paint {
+	if p == nil {
+		return nil
+	}
+	return &Paint{Kind: p.Kind, Params: slices.Clone(p.Params), Colors: slices.Clone(p.Colors)}
+}
+
+// shader повертає функцію, яка обчислює колір заливки в точці (x, y) області розміром w на h пікселів. Координати
+// точки відраховуються від лівого верхнього кута області. Заливка має бути перевірена Validate.
+func (p *Paint) shader(w, h float64) func(x, y float64) color.RGBA64 {
+	stops := make([]color.RGBA64, len(p.Colors))
+	for i, c := range p.Colors {
+		stops[i] = color.RGBA64Model.Convert(c).(color.RGBA64)
+	}
+	s := min(w, h)
+	switch p.Kind {
+	case "linear":
+		x1, y1, x2, y2 := p.Params[0]*w, p.Params[1]*h, p.Params[2]*w, p.Params[3]*h
+		dx, dy := x2-x1, y2-y1
+		l2 := dx*dx + dy*dy
+		return func(x, y float64) color.RGBA64 {
+			if l2 == 0 {
+				return stops[0]
+			}
+			return gradient(stops, ((x-x1)*dx+(y-y1)*dy)/l2)
+		}
+	case "radial":
+		cx, cy, r := p.Params[0]*w, p.Params[1]*h, p.Params[2]*s
+		return func(x, y float64) color.RGBA64 {
+			return gradient(stops, math.Hypot(x-cx, y-cy)/r)
+		}
+	case "checker":
+		size := p.Params[0] * s
+		return func(x, y float64) color.RGBA64 {
+			return stops[cycle(math.Floor(x/size)+math.Floor(y/size), len(stops))]
+		}
+	default:
+		width, angle := p.Params[0]*s, 0.0
+		if len(p.Params) > 1 {
+			angle = p.Params[1]
+		}
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		return func(x, y float64) color.RGBA64 {
+			return stops[cycle(math.Floor((x*cos+y*sin)/width), len(stops))]
+		}
+	}
+}
+
+// gradient повертає колір у точці t від 0 до 1 градієнта з рівномірно розподіленими кольорами stops. Поза цим
+// проміжком градієнт продовжується крайніми кольорами.
+func gradient(stops []color.RGBA64, t float64) color.RGBA64 {
+	if !(t > 0) {
+		return stops[0]
+	}
+	if t >= 1 {
+		return stops[len(stops)-1]
+	}
+	pos := t * float64(len(stops)-1)
+	i := int(pos)
+	f := pos - float64(i)
+	a, b := stops[i], stops[i+1]
+	lerp := func(a, b uint16) uint16 { return uint16(float64(a) + (float64(b)-float64(a))*f + 0.5) }
+	return color.RGBA64{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: lerp(a.A, b.A)}
+}
+
+// cycle повертає індекс кольору клітинки або смуги з номером v серед n кольорів.
+func cycle(v float64, n int) int {
+	k := math.Mod(v, float64(n))
+	if math.IsNaN(k) {
+		return 0
+	}
+	if k < 0 {
+		k += float64(n)
+	}
+	return min(int(k), n-1)
+}
+
+// FillPaint заливає градієнтом або візерунком фон сцени або, якщо задано ID, прямокутник з цим ідентифікатором.
+// Заливку скасовує Fill для фону та Recolor для прямокутника. Невідповідна заливка нічого не змінює.
+type FillPaint struct {
+	ID    string
+	Paint Paint
+}
+
+func (op FillPaint) Do(t screen.Texture, s *Scene) bool {
+	if op.Paint.Validate() != nil {
+		return false
+	}
+	p := op.Paint.clone()
+	if op.ID == "" {
+		s.BgPaint = p
+	} else if i := s.Rect(op.ID); i >= 0 {
+		s.Rects[i].Paint = p
+	} else {
+		return false
+	}
+	render(s, t)
+	return false
+}
+
+// drawBackground заливає все полотно заливкою p без накладання, як і суцільний фон.
+func drawBackground(t screen.Texture, p *Paint) {
+	b := t.Bounds()
+	shade := p.shader(float64(b.Dx()), float64(b.Dy()))
+	layer := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			layer.SetRGBA64(x, y, shade(float64(x-b.Min.X)+0.5, float64(y-b.Min.Y)+0.5))
+		}
+	}
+	drawLayer(t, layer, 1, draw.Src)
+}
+
+// paintRect заливає частини parts прямокутника r заливкою p з перетворенням m його групи. Заливка прив'язана до r,
+// тож повертається і масштабується разом з ним. Як і при суцільній заливці, зафарбовуються пікселі, центри яких
+// лежать усередині частин.
+func paintRect(t screen.Texture, r image.Rectangle, parts []image.Rectangle, m affine, p *Paint) {
+	inv, ok := m.invert()
+	if !ok {
+		return
+	}
+	corners := func(r image.Rectangle) [][2]float64 {
+		pts := make([][2]float64, 4)
+		for i, c := range [4]image.Point{r.Min, {r.Max.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}} {
+			pts[i][0], pts[i][1] = m.apply(float64(c.X), float64(c.Y))
+		}
+		return pts
+	}
+	area := image.Rectangle{}
+	for _, c := range corners(r) {
+		area = area.Union(image.Rect(int(math.Floor(c[0])), int(math.Floor(c[1])), int(math.Ceil(c[0]))+1, int(math.Ceil(c[1]))+1))
+	}
+	area = area.Intersect(t.Bounds())
+	if area.Empty() {
+		return
+	}
+
+	shade := p.shader(float64(r.Dx()), float64(r.Dy()))
+	layer := image.NewRGBA(area)
+	for _, part := range parts {
+		polygonSpans(corners(part), area, func(x0, x1, y int) {
+			for x := x0; x < x1; x++ {
+				lx, ly := inv.apply(float64(x)+0.5, float64(y)+0.5)
+				layer.SetRGBA64(x, y, shade(lx-float64(r.Min.X), ly-float64(r.Min.Y)))
+			}
+		})
+	}
+	drawLayer(t, layer, 1, draw.Over)
+}
@@ -0,0 +1,130 @@
+package painter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+)
+
+func TestRender_Paint(t *testing.T) {
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(100, 100))
+	img := tx.(*headless.Texture).RGBA()
+	black, white := color.RGBA{A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	bw := []color.Color{color.White, color.Black}
+	gray := func(p image.Point) int { return int(img.RGBAAt(p.X, p.Y).R) }
+
+	renderPrimitives(tx, FillPaint{Paint: Paint{Kind: "linear", Params: []float64{0, 0, 1, 0}, Colors: bw}})
+	if g := gray(image.Pt(0, 50)); g < 250 {
+		t.Errorf("linear gradient should start white, got %d", g)
+	}
+	if g := gray(image.Pt(49, 10)); g < 125 || g > 131 {
+		t.Errorf("linear gradient should be gray in the middle, got %d", g)
+	}
+	if g := gray(image.Pt(99, 90)); g > 5 {
+		t.Errorf("linear gradient should end black, got %d", g)
+	}
+
+	three := append(bw, color.White)
+	renderPrimitives(tx, FillPaint{Paint: Paint{Kind: "radial", Params: []float64{0.5, 0.5, 0.5}, Colors: three}})
+	if gray(image.Pt(50, 50)) < 245 || gray(image.Pt(25, 50)) > 10 || gray(image.Pt(0, 0)) != 255 {
+		t.Errorf("unexpected radial gradient: %d %d %d", gray(image.Pt(50, 50)), gray(image.Pt(25, 50)), gray(image.Pt(0, 0)))
+	}
+
+	renderPrimitives(tx, FillPaint{Paint: Paint{Kind: "checker", Params: []float64{0.1}, Colors: bw}})
+	for p, want := range map[image.Point]color.RGBA{{5, 5}: white, {15, 5}: black, {15, 15}: white, {95, 5}: black} {
+		if got := img.RGBAAt(p.X, p.Y); got != want {
+			t.Errorf("checker: pixel %v = %v, want %v", p, got, want)
+		}
+	}
+
+	renderPrimitives(tx, FillPaint{Paint: Paint{Kind: "stripes", Params: []float64{0.1, 90}, Colors: bw}})
+	if img.RGBAAt(50, 5) != white || img.RGBAAt(50, 15) != black || img.RGBAAt(5, 15) != black {
+		t.Errorf("stripes at 90 degrees should be horizontal")
+	}
+
+	rect := []Operation{
+		AddRect{ID: "r", X1: 0.2, Y1: 0.2, X2: 0.6, Y2: 0.4},
+		FillPaint{ID: "r", Paint: Paint{Kind: "linear", Params: []float64{0, 0, 1, 0}, Colors: bw}},
+	}
+	renderPrimitives(tx, rect...)
+	if gray(image.Pt(20, 30)) < 245 || gray(image.Pt(59, 30)) > 10 ||
+		img.RGBAAt(10, 30) != white || img.RGBAAt(61, 30) != white {
+		t.Errorf("gradient should span the rectangle: %d %d", gray(image.Pt(20, 30)), gray(image.Pt(59, 30)))
+	}
+
+	rotated := append([]Operation{AddGroup{ID: "g"}}, rect...)
+	rotated = append(rotated, Attach{ID: "r", Group: "g"}, PivotGroup{ID: "g", X: 0.4, Y: 0.3}, RotateGroup{ID: "g", Angle: 90})
+	renderPrimitives(tx, rotated...)
+	if gray(image.Pt(45, 10)) < 245 || gray(image.Pt(45, 49)) > 10 {
+		t.Errorf("gradient should rotate with the rectangle: %d %d", gray(image.Pt(45, 10)), gray(image.Pt(45, 49)))
+	}
+
+	// Через t.Fill заливка малюється так само, як напряму у зображення.
+	translucent := []color.Color{color.NRGBA{R: 255, A: 128}, color.Black}
+	bg := FillPaint{Paint: Paint{Kind: "radial", Params: []float64{0.3, 0.3, 0.4}, Colors: translucent}}
+	for _, ops := range [][]Operation{{bg}, append([]Operation{bg}, rotated...)} {
+		renderPrimitives(tx, ops...)
+		want := headless.CloneRGBA(img, nil)
+		renderPrimitives(fillOnly{tx}, ops...)
+		for i := range img.Pix {
+			if d := int(img.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+				t.Fatalf("fill fallback differs at byte %d: %d != %d", i, img.Pix[i], want.Pix[i])
+			}
+		}
+	}
+}
+
+func TestPaint_SceneModel(t *testing.T) {
+	bw := []color.Color{color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBA{A: 255}}
+	for _, p := range []Paint{
+		{Kind: "conic", Params: []float64{0}, Colors: bw},
+		{Kind: "linear", Params: []float64{0, 0, 1}, Colors: bw},
+		{Kind: "radial", Params: []float64{0, 0, 0}, Colors: bw},
+		{Kind: "checker", Params: []float64{0.1}, Colors: bw[:1]},
+		{Kind: "stripes", Params: []float64{-0.1}, Colors: bw},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%+v: expected error", p)
+		}
+	}
+
+	var s Scene
+	checker := Paint{Kind: "checker", Params: []float64{0.1}, Colors: bw}
+	applyOps(&s,
+		AddRect{ID: "r", X2: 1, Y2: 1}, AddRect{ID: "q", X2: 1, Y2: 1},
+		Record{}, FillPaint{Paint: checker}, FillPaint{ID: "r", Paint: checker}, FillPaint{ID: "q", Paint: checker},
+		FillPaint{ID: "missing", Paint: checker}, Recolor{ID: "q", Color: bw[1]},
+	)
+	if s.BgPaint == nil || s.Rects[0].Paint == nil || s.Rects[1].Paint != nil {
+		t.Fatalf("unexpected paints: %+v %+v", s.BgPaint, s.Rects)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeScene(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeScene(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.BgPaint, s.BgPaint) || !reflect.DeepEqual(got.Rects, s.Rects) {
+		t.Errorf("expected %+v %+v, got %+v %+v", s.BgPaint, s.Rects, got.BgPaint, got.Rects)
+	}
+	data := `{"version": 6, "background_paint": {"kind": "linear", "params": [0, 0, 1, 1], "colors": ["#ffffffff"]}}`
+	if _, err := DecodeScene(bytes.NewBufferString(data)); err == nil {
+		t.Error("expected error for a gradient with one color")
+	}
+
+	applyOps(&s, Fill{Color: color.White})
+	if s.BgPaint != nil {
+		t.Errorf("solid fill should replace the background paint")
+	}
+	applyOps(&s, Undo{})
+	if s.BgPaint != nil || s.Rects[0].Paint != nil {
+		t.Errorf("undo should remove the paints: %+v %+v", s.BgPaint, s.Rects)
+	}
+}
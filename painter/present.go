@@ -0,0 +1,132 @@
+package painter
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// FrameStats містить лічильники кадрів циклу подій.
+type FrameStats struct {
+	Rendered  uint64 // кадри, сформовані операціями
+	Delivered uint64 // кадри, передані у Receiver
+	Coalesced uint64 // кадри, замінені новішими в межах одного інтервалу FPS
+	Dropped   uint64 // кадри, замінені новішими, поки Receiver обробляв попередній
+}
+
+// presenter передає готові кадри у Receiver в окремій горутині не частіше за раз на interval. Якщо за цей час
+// з'являється кілька кадрів, у Receiver потрапляє лише останній, тож повільний Receiver не блокує виконання операцій.
+// Receiver може показувати отриману текстуру й після повернення з Update, тому вона повертається циклу лише тоді,
+// коли Receiver отримає наступний кадр.
+type presenter struct {
+	receiver Receiver
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending screen.Texture   // останній готовий кадр, який ще не передано
+	shown   screen.Texture   // останній переданий кадр, який Receiver ще може показувати
+	free    []screen.Texture // текстури, які можна повернути циклу для наступного кадру
+	busy    bool             // виконується Receiver.Update
+	stats   FrameStats
+
+	wake chan struct{} // сигнал про новий кадр, буфер на одне значення
+	quit chan struct{}
+	done chan struct{}
+}
+
+func newPresenter(r Receiver, interval time.Duration, free ...screen.Texture) *presenter {
+	p := &presenter{
+		receiver: r,
+		interval: interval,
+		free:     free,
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// publish віддає готовий кадр t і повертає текстуру, на якій цикл формуватиме наступний.
+func (p *presenter) publish(t screen.Texture) screen.Texture {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Rendered++
+	var next screen.Texture
+	if p.pending != nil {
+		next = p.pending
+		if p.busy {
+			p.stats.Dropped++
+		} else {
+			p.stats.Coalesced++
+		}
+	} else {
+		next = p.free[len(p.free)-1]
+		p.free = p.free[:len(p.free)-1]
+	}
+	p.pending = t
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return next
+}
+
+func (p *presenter) run() {
+	defer close(p.done)
+	var last time.Time
+	for {
+		select {
+		case <-p.wake:
+		case <-p.quit:
+			p.deliver() // Останній кадр передається без очікування інтервалу.
+			return
+		}
+		if wait := p.interval - time.Since(last); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-p.quit:
+				timer.Stop()
+			}
+		}
+		last = time.Now()
+		p.deliver()
+	}
+}
+
+// deliver передає у Receiver кадр, який очікує, якщо він є.
+func (p *presenter) deliver() {
+	p.mu.Lock()
+	t := p.pending
+	p.pending = nil
+	p.busy = t != nil
+	p.mu.Unlock()
+	if t == nil {
+		return
+	}
+
+	p.receiver.Update(t)
+
+	p.mu.Lock()
+	p.busy = false
+	if p.shown != nil {
+		p.free = append(p.free, p.shown)
+	}
+	p.shown = t
+	p.stats.Delivered++
+	p.mu.Unlock()
+}
+
+// stop передає останній кадр та чекає завершення горутини.
+func (p *presenter) stop() {
+	close(p.quit)
+	<-p.done
+}
+
+func (p *presenter) frameStats() FrameStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
@@ -0,0 +1,306 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Primitive — фігура, вигляд якої визначає вид Kind з реєстру: коло, многокутник, зірка тощо. Params — параметри
+// виду; координати в них нормалізовані, а радіуси та інші довжини задаються в частках меншої сторони полотна, щоб
+// коло лишалося колом на неквадратному полотні. X та Y зсувають примітив відносно координат з Params; так його
+// переміщує MoveShapes незалежно від виду.
+type Primitive struct {
+	ID     string
+	Kind   string
+	Params []float64
+	X      float64
+	Y      float64
+	Fill   color.Color // колір заливки замкнених контурів; nil означає без заливки
+	Stroke color.Color // колір контуру; nil означає без контуру
+	Width  float64     // товщина контуру в частках меншої сторони полотна; 0 означає DefaultStrokeWidth
+	Z      int
+	Parent string // група, в якій лежить фігура; координати задані в її системі
+}
+
+// DefaultStrokeWidth — товщина контуру примітивів, для яких її не задано.
+const DefaultStrokeWidth = 0.005
+
+// Path — ламана у пікселях полотна. Заливаються лише замкнені ламані, у яких остання точка з'єднується з першою.
+type Path struct {
+	Points [][2]float64
+	Closed bool
+}
+
+// Kind будує контури примітиву за його параметрами на полотні b. Якщо параметри не підходять виду, повертається
+// помилка.
+type Kind func(params []float64, b image.Rectangle) ([]Path, error)
+
+var kinds = map[string]Kind{
+	"circle":    circleKind,
+	"ellipse":   ellipseKind,
+	"line":      lineKind,
+	"polyline":  polylineKind,
+	"polygon":   polygonKind,
+	"triangle":  triangleKind,
+	"star":      starKind,
+	"roundrect": roundRectKind,
+}
+
+// RegisterKind додає вид примітиву до реєстру або замінює наявний. Реєструвати види слід до запуску циклу подій.
+func RegisterKind(name string, k Kind) {
+	kinds[name] = k
+}
+
+// Kinds повертає назви зареєстрованих видів примітивів за абеткою.
+func Kinds() []string {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Validate перевіряє, що вид примітиву зареєстровано і параметри та зсув йому підходять.
+func (p *Primitive) Validate() error {
+	if math.IsNaN(p.X) || math.IsInf(p.X, 0) || math.IsNaN(p.Y) || math.IsInf(p.Y, 0) {
+		return fmt.Errorf("%s offset must be finite, got %g %g", p.Kind, p.X, p.Y)
+	}
+	for _, v := range p.Params {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%s parameters must be finite numbers", p.Kind)
+		}
+	}
+	_, err := p.paths(image.Rect(0, 0, 1, 1))
+	return err
+}
+
+// paths будує контури примітиву на полотні b з урахуванням зсуву.
+func (p *Primitive) paths(b image.Rectangle) ([]Path, error) {
+	k, ok := kinds[p.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown primitive kind %q", p.Kind)
+	}
+	paths, err := k(p.Params, b)
+	if err != nil || p.X == 0 && p.Y == 0 {
+		return paths, err
+	}
+	dx, dy := p.X*float64(b.Dx()), p.Y*float64(b.Dy())
+	for i := range paths {
+		for j := range paths[i].Points {
+			paths[i].Points[j][0] += dx
+			paths[i].Points[j][1] += dy
+		}
+	}
+	return paths, nil
+}
+
+// center повертає центр прямокутника, який охоплює контури примітиву, у нормалізованих координатах. Радіуси
+// відкладаються однаково в обидва боки, тож центр не залежить від пропорцій полотна.
+func (p *Primitive) center() (x, y float64, ok bool) {
+	paths, err := p.paths(image.Rect(0, 0, 1, 1))
+	if err != nil {
+		return 0, 0, false
+	}
+	x0, y0, x1, y1 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, path := range paths {
+		for _, pt := range path.Points {
+			x0, y0, x1, y1 = min(x0, pt[0]), min(y0, pt[1]), max(x1, pt[0]), max(y1, pt[1])
+		}
+	}
+	if x0 > x1 {
+		return 0, 0, false
+	}
+	return (x0 + x1) / 2, (y0 + y1) / 2, true
+}
+
+// Primitive повертає індекс примітиву з указаним ідентифікатором або -1, якщо такого немає.
+func (s *Scene) Primitive(id string) int {
+	for i := range s.Primitives {
+		if s.Primitives[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddPrimitive додає примітив у сцену поверх інших елементів. Якщо ID порожній, примітиву призначається новий
+// ідентифікатор; якщо елемент з таким ID вже існує, примітив займає його місце та z-індекс, а без Parent — і його
+// групу. Примітив невідомого виду або з невідповідними параметрами не додається.
+type AddPrimitive Primitive
+
+func (op AddPrimitive) Do(t screen.Texture, s *Scene) bool {
+	p := Primitive(op)
+	p.Params = slices.Clone(p.Params)
+	if p.Validate() != nil {
+		return false
+	}
+	if p.ID == "" {
+		p.ID = s.newID("p")
+	}
+	if p.Parent == "" {
+		if parent := s.parent(p.ID); parent != nil {
+			p.Parent = *parent
+		}
+	}
+	p.Parent = s.validParent(p.ID, p.Parent)
+	if z := s.z(p.ID); z != nil {
+		p.Z = *z
+	} else {
+		p.Z = s.topZ()
+	}
+	if i := s.Primitive(p.ID); i >= 0 {
+		s.Primitives[i] = p
+	} else {
+		s.remove(p.ID)
+		s.Primitives = append(s.Primitives, p)
+	}
+	render(s, t)
+	return false
+}
+
+// need перевіряє кількість параметрів виду.
+func need(kind string, params []float64, lo, hi int) error {
+	if len(params) < lo || len(params) > hi {
+		if lo == hi {
+			return fmt.Errorf("%s requires %d parameters, got %d", kind, lo, len(params))
+		}
+		return fmt.Errorf("%s requires %d to %d parameters, got %d", kind, lo, hi, len(params))
+	}
+	return nil
+}
+
+// points переводить пари нормалізованих координат у пікселі полотна b.
+func points(params []float64, b image.Rectangle) [][2]float64 {
+	pts := make([][2]float64, len(params)/2)
+	for i := range pts {
+		pts[i] = [2]float64{params[2*i] * float64(b.Dx()), params[2*i+1] * float64(b.Dy())}
+	}
+	return pts
+}
+
+// side повертає довжину меншої сторони полотна, в частках якої задаються довжини.
+func side(b image.Rectangle) float64 {
+	return float64(min(b.Dx(), b.Dy()))
+}
+
+// arc додає до pts дугу еліпса з центром (cx, cy) від кута a0 до a1 у радіанах.
+func arc(pts [][2]float64, cx, cy, rx, ry, a0, a1 float64) [][2]float64 {
+	// Хорди завдовжки близько трьох пікселів відхиляються від дуги значно менше ніж на піксель.
+	n := int(math.Ceil(math.Abs(a1-a0) * max(rx, ry) / 3))
+	n = min(max(n, 4), 1024)
+	for i := range n + 1 {
+		sin, cos := math.Sincos(a0 + (a1-a0)*float64(i)/float64(n))
+		pts = append(pts, [2]float64{cx + rx*cos, cy + ry*sin})
+	}
+	return pts
+}
+
+func ellipsePath(cx, cy, rx, ry float64) []Path {
+	pts := arc(nil, cx, cy, rx, ry, 0, 2*math.Pi)
+	return []Path{{Points: pts[:len(pts)-1], Closed: true}}
+}
+
+// circleKind: x y r.
+func circleKind(params []float64, b image.Rectangle) ([]Path, error) {
+	if err := need("circle", params, 3, 3); err != nil {
+		return nil, err
+	}
+	c := points(params[:2], b)[0]
+	r := params[2] * side(b)
+	return ellipsePath(c[0], c[1], r, r), nil
+}
+
+// ellipseKind: x y rx ry.
+func ellipseKind(params []float64, b image.Rectangle) ([]Path, error) {
+	if err := need("ellipse", params, 4, 4); err != nil {
+		return nil, err
+	}
+	c := points(params[:2], b)[0]
+	return ellipsePath(c[0], c[1], params[2]*side(b), params[3]*side(b)), nil
+}
+
+// lineKind: x1 y1 x2 y2.
+func lineKind(params []float64, b image.Rectangle) ([]Path, error) {
+	if err := need("line", params, 4, 4); err != nil {
+		return nil, err
+	}
+	return []Path{{Points: points(params, b)}}, nil
+}
+
+// polylineKind: x1 y1 x2 y2 … — щонайменше дві точки.
+func polylineKind(params []float64, b image.Rectangle) ([]Path, error) {
+	if len(params) < 4 || len(params)%2 != 0 {
+		return nil, fmt.Errorf("polyline requires at least 2 points as x y pairs, got %d numbers", len(params))
+	}
+	return []Path{{Points: points(params, b)}}, nil
+}
+
+// polygonKind: x1 y1 x2 y2 x3 y3 … — щонайменше три точки.
+func polygonKind(params []float64, b image.Rectangle) ([]Path, error) {
+	if len(params) < 6 || len(params)%2 != 0 {
+		return nil, fmt.Errorf("polygon requires at least 3 points as x y pairs, got %d numbers", len(params))
+	}
+	return []Path{{Points: points(params, b), Closed: true}}, nil
+}
+
+// triangleKind: x1 y1 x2 y2 x3 y3.
+func triangleKind(params []float64, b image.Rectangle) ([]Path, error) {
+	if err := need("triangle", params, 6, 6); err != nil {
+		return nil, err
+	}
+	return []Path{{Points: points(params, b), Closed: true}}, nil
+}
+
+// starKind: x y r [n] [inner] — зірка з n променями (типово 5), внутрішній радіус якої становить частку inner
+// зовнішнього (типово 0.5). Перший промінь напрямлений угору.
+func starKind(params []float64, b image.Rectangle) ([]Path, error) {
+	if err := need("star", params, 3, 5); err != nil {
+		return nil, err
+	}
+	n, inner := 5, 0.5
+	if len(params) > 3 {
+		if params[3] != math.Trunc(params[3]) || params[3] < 2 || params[3] > 1000 {
+			return nil, fmt.Errorf("star requires 2 to 1000 points, got %g", params[3])
+		}
+		n = int(params[3])
+	}
+	if len(params) > 4 {
+		inner = params[4]
+	}
+	c := points(params[:2], b)[0]
+	r := params[2] * side(b)
+	pts := make([][2]float64, 2*n)
+	for i := range pts {
+		rr := r
+		if i%2 == 1 {
+			rr = r * inner
+		}
+		sin, cos := math.Sincos(-math.Pi/2 + math.Pi*float64(i)/float64(n))
+		pts[i] = [2]float64{c[0] + rr*cos, c[1] + rr*sin}
+	}
+	return []Path{{Points: pts, Closed: true}}, nil
+}
+
+// roundRectKind: x1 y1 x2 y2 radius — прямокутник із заокругленими кутами. Радіус обмежується половиною меншої
+// сторони прямокутника.
+func roundRectKind(params []float64, b image.Rectangle) ([]Path, error) {
+	if err := need("roundrect", params, 5, 5); err != nil {
+		return nil, err
+	}
+	pts := points(params[:4], b)
+	x0, x1 := min(pts[0][0], pts[1][0]), max(pts[0][0], pts[1][0])
+	y0, y1 := min(pts[0][1], pts[1][1]), max(pts[0][1], pts[1][1])
+	r := min(max(params[4]*side(b), 0), (x1-x0)/2, (y1-y0)/2)
+	var path [][2]float64
+	path = arc(path, x1-r, y0+r, r, r, -math.Pi/2, 0)
+	path = arc(path, x1-r, y1-r, r, r, 0, math.Pi/2)
+	path = arc(path, x0+r, y1-r, r, r, math.Pi/2, math.Pi)
+	path = arc(path, x0+r, y0+r, r, r, math.Pi, 3*math.Pi/2)
+	return []Path{{Points: path, Closed: true}}, nil
+}
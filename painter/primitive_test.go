@@ -0,0 +1,199 @@
+package painter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// fillOnly приховує метод RGBA текстури, тож растеризатор малює через t.Fill, як на текстурах віконного драйвера.
+type fillOnly struct {
+	screen.Texture
+}
+
+func renderPrimitives(t screen.Texture, ops ...Operation) {
+	s := Scene{BgColor: color.White}
+	for _, op := range ops {
+		op.Do(t, &s)
+	}
+}
+
+func TestRender_Primitives(t *testing.T) {
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(400, 400))
+	img := tx.(*headless.Texture).RGBA()
+	red := color.RGBA{R: 255, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	for _, tc := range []struct {
+		name  string
+		op    AddPrimitive
+		red   []image.Point
+		white []image.Point
+	}{
+		{
+			name:  "circle",
+			op:    AddPrimitive{Kind: "circle", Params: []float64{0.5, 0.5, 0.25}, Fill: red},
+			red:   []image.Point{{200, 200}, {200, 102}, {297, 200}},
+			white: []image.Point{{200, 97}, {125, 125}},
+		},
+		{
+			name:  "circle outline",
+			op:    AddPrimitive{Kind: "circle", Params: []float64{0.5, 0.5, 0.25}, Stroke: red, Width: 0.01},
+			red:   []image.Point{{200, 100}, {300, 200}},
+			white: []image.Point{{200, 200}, {200, 110}},
+		},
+		{
+			name:  "ellipse",
+			op:    AddPrimitive{Kind: "ellipse", Params: []float64{0.5, 0.5, 0.4, 0.1}, Fill: red},
+			red:   []image.Point{{45, 200}, {200, 165}},
+			white: []image.Point{{200, 130}},
+		},
+		{
+			name:  "thick line with flat ends",
+			op:    AddPrimitive{Kind: "line", Params: []float64{0.25, 0.5, 0.75, 0.5}, Stroke: red, Width: 0.02},
+			red:   []image.Point{{200, 197}, {200, 203}, {101, 200}},
+			white: []image.Point{{200, 190}, {97, 200}},
+		},
+		{
+			name:  "polyline joint",
+			op:    AddPrimitive{Kind: "polyline", Params: []float64{0.25, 0.25, 0.5, 0.5, 0.25, 0.75}, Stroke: red, Width: 0.05},
+			red:   []image.Point{{200, 200}, {205, 200}, {150, 150}},
+			white: []image.Point{{150, 200}},
+		},
+		{
+			name:  "polygon",
+			op:    AddPrimitive{Kind: "polygon", Params: []float64{0.25, 0.25, 0.75, 0.25, 0.75, 0.75, 0.5, 0.5}, Fill: red},
+			red:   []image.Point{{200, 150}, {280, 250}},
+			white: []image.Point{{150, 250}},
+		},
+		{
+			name:  "triangle",
+			op:    AddPrimitive{Kind: "triangle", Params: []float64{0.5, 0.25, 0.75, 0.75, 0.25, 0.75}, Fill: red},
+			red:   []image.Point{{200, 110}, {200, 290}},
+			white: []image.Point{{120, 120}},
+		},
+		{
+			name:  "star",
+			op:    AddPrimitive{Kind: "star", Params: []float64{0.5, 0.5, 0.25}, Fill: red},
+			red:   []image.Point{{200, 200}, {200, 105}},
+			white: []image.Point{{260, 130}},
+		},
+		{
+			name:  "rounded rect",
+			op:    AddPrimitive{Kind: "roundrect", Params: []float64{0.25, 0.25, 0.75, 0.75, 0.1}, Fill: red},
+			red:   []image.Point{{200, 101}, {101, 200}, {200, 200}},
+			white: []image.Point{{102, 102}, {297, 297}},
+		},
+	} {
+		renderPrimitives(tx, tc.op)
+		for _, p := range tc.red {
+			if got := img.RGBAAt(p.X, p.Y); got != red {
+				t.Errorf("%s: pixel %v = %v, want red", tc.name, p, got)
+			}
+		}
+		for _, p := range tc.white {
+			if got := img.RGBAAt(p.X, p.Y); got != white {
+				t.Errorf("%s: pixel %v = %v, want white", tc.name, p, got)
+			}
+		}
+	}
+}
+
+func TestRender_PrimitiveAntialiasing(t *testing.T) {
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(400, 400))
+	img := tx.(*headless.Texture).RGBA()
+	// Правий край кола проходить посередині пікселя 240.
+	op := AddPrimitive{Kind: "circle", Params: []float64{200.5 / 400, 0.5, 0.1}, Fill: color.Black}
+	renderPrimitives(tx, op)
+	if got := img.RGBAAt(240, 200); got.R < 100 || got.R > 155 {
+		t.Errorf("expected a half-covered edge pixel, got %v", got)
+	}
+
+	// Через t.Fill растеризатор малює те саме, що й напряму у зображення.
+	want := headless.CloneRGBA(img, nil)
+	for _, op := range []AddPrimitive{op, {Kind: "star", Params: []float64{0.3, 0.3, 0.2}, Fill: color.NRGBA{B: 255, A: 128}}} {
+		renderPrimitives(tx, op)
+		want = headless.CloneRGBA(img, want)
+		renderPrimitives(fillOnly{tx}, op)
+		for i := range img.Pix {
+			if d := int(img.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+				t.Fatalf("%s: fill fallback differs at byte %d: %d != %d", op.Kind, i, img.Pix[i], want.Pix[i])
+			}
+		}
+	}
+}
+
+func TestPrimitives_Registry(t *testing.T) {
+	RegisterKind("test-square", func(params []float64, b image.Rectangle) ([]Path, error) {
+		pts := [][2]float64{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+		return []Path{{Points: pts, Closed: true}}, nil
+	})
+	defer delete(kinds, "test-square")
+
+	var s Scene
+	applyOps(&s,
+		AddPrimitive{ID: "a", Kind: "test-square"},
+		AddPrimitive{ID: "b", Kind: "missing"},
+		AddPrimitive{ID: "c", Kind: "circle", Params: []float64{0.5}},
+		AddPrimitive{ID: "nan", Kind: "circle", Params: []float64{0.5, 0.5, math.NaN()}},
+		AddPrimitive{ID: "inf", Kind: "polygon", Params: []float64{0, 0, 1, 0, math.Inf(1), 1}},
+		AddPrimitive{ID: "a", Kind: "circle", Params: []float64{0.5, 0.5, 0.1}},
+		Recolor{ID: "a", Color: color.Black},
+	)
+	if len(s.Primitives) != 1 || s.Primitives[0].Kind != "circle" || s.Primitives[0].Fill != color.Black {
+		t.Errorf("unexpected primitives %+v", s.Primitives)
+	}
+
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(20, 20))
+	renderPrimitives(tx, AddPrimitive{Kind: "test-square", Fill: color.Black})
+	if got := tx.(*headless.Texture).RGBA().RGBAAt(5, 5); got != (color.RGBA{A: 255}) {
+		t.Errorf("registered kind was not drawn, got %v", got)
+	}
+	// Зсув переміщує примітив будь-якого виду, зокрема зареєстрованого.
+	renderPrimitives(tx, AddPrimitive{ID: "q", Kind: "test-square", Fill: color.Black}, MoveShapes{ID: "q", X: 0.5, Y: 0.5, Relative: true})
+	if img := tx.(*headless.Texture).RGBA(); img.RGBAAt(5, 5).R != 255 || img.RGBAAt(15, 15) != (color.RGBA{A: 255}) {
+		t.Errorf("registered kind was not moved: %v %v", img.RGBAAt(5, 5), img.RGBAAt(15, 15))
+	}
+}
+
+func TestSceneFile_Primitives(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddPrimitive{ID: "c", Kind: "circle", Params: []float64{0.5, 0.5, 0.1}, Stroke: color.NRGBA{R: 255, A: 255}, Width: 0.02},
+		AddPrimitive{ID: "p", Kind: "polygon", Params: []float64{0, 0, 1, 0, 1, 1}, Fill: color.NRGBA{B: 255, A: 255}},
+		MoveShapes{ID: "c", X: 0.2, Y: 0.3}, MoveShapes{ID: "p", X: 0.1, Y: -0.1, Relative: true},
+	)
+	if x, y, _ := s.Primitives[0].center(); !near(x, 0.2) || !near(y, 0.3) {
+		t.Errorf("circle should be centered at the target point, got %g %g", x, y)
+	}
+	if p := s.Primitives[1]; !near(p.X, 0.1) || !near(p.Y, -0.1) {
+		t.Errorf("polygon should be shifted, got %+v", p)
+	}
+	var buf bytes.Buffer
+	if err := EncodeScene(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeScene(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Primitives, s.Primitives) {
+		t.Errorf("expected %+v, got %+v", s.Primitives, got.Primitives)
+	}
+
+	for _, data := range []string{
+		`{"version": 3, "primitives": [{"id": "a", "kind": "blob", "params": []}]}`,
+		`{"version": 3, "primitives": [{"id": "a", "kind": "line", "params": [0, 0]}]}`,
+	} {
+		if _, err := DecodeScene(bytes.NewBufferString(data)); err == nil {
+			t.Errorf("%s: expected error", data)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/vector"
+)
+
+// drawPrimitive малює примітив з перетворенням m його групи. Контури растеризуються зі згладжуванням: край фігури
+// накладається на піксель з прозорістю, пропорційною частці пікселя, яку він покриває. Якщо не задано ні заливки,
+// ні контуру, замкнені фігури заливаються, а відкриті обводяться кольором DefaultShapeColor.
+func drawPrimitive(t screen.Texture, p Primitive, m affine) {
+	b := t.Bounds()
+	paths, err := p.paths(b)
+	if err != nil {
+		return
+	}
+	closed := false
+	for i := range paths {
+		closed = closed || paths[i].Closed
+		for j, pt := range paths[i].Points {
+			paths[i].Points[j][0], paths[i].Points[j][1] = m.apply(pt[0], pt[1])
+		}
+	}
+	fill, stroke := p.Fill, p.Stroke
+	if fill == nil && stroke == nil {
+		if closed {
+			fill = DefaultShapeColor
+		} else {
+			stroke = DefaultShapeColor
+		}
+	}
+
+	if fill != nil {
+		var contours [][][2]float64
+		for _, path := range paths {
+			if path.Closed {
+				contours = append(contours, path.Points)
+			}
+		}
+		rasterize(t, contours, fill)
+	}
+	if stroke != nil {
+		width := p.Width
+		if width <= 0 {
+			width = DefaultStrokeWidth
+		}
+		// Товщина контуру змінюється разом з масштабом групи.
+		width *= side(b) * math.Sqrt(math.Abs(m.a*m.d-m.b*m.c))
+		var contours [][][2]float64
+		for _, path := range paths {
+			contours = strokePath(contours, path, width)
+		}
+		rasterize(t, contours, stroke)
+	}
+}
+
+// strokePath додає до contours многокутники, які разом утворюють контур ламаної path товщиною width: прямокутник
+// уздовж кожного відрізка та коло у кожному зламі. Кінці незамкненої ламаної обрізаються рівно.
+func strokePath(contours [][][2]float64, path Path, width float64) [][][2]float64 {
+	pts := path.Points
+	n := len(pts) - 1
+	if path.Closed {
+		n = len(pts)
+	}
+	h := width / 2
+	for i := range n {
+		p, q := pts[i], pts[(i+1)%len(pts)]
+		dx, dy := q[0]-p[0], q[1]-p[1]
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		nx, ny := -dy/l*h, dx/l*h
+		contours = append(contours, oriented([][2]float64{
+			{p[0] + nx, p[1] + ny}, {q[0] + nx, q[1] + ny}, {q[0] - nx, q[1] - ny}, {p[0] - nx, p[1] - ny},
+		}))
+	}
+	for i, p := range pts {
+		if path.Closed || i > 0 && i < len(pts)-1 {
+			joint := arc(nil, p[0], p[1], h, h, 0, 2*math.Pi)
+			contours = append(contours, oriented(joint[:len(joint)-1]))
+		}
+	}
+	return contours
+}
+
+// oriented повертає многокутник з обходом за годинниковою стрілкою. Растеризатор додає покриття многокутників з
+// однаковим обходом, а з протилежним — віднімає, тож частини контуру мають бути обернені однаково, щоб їхні
+// перетини не ставали дірками.
+func oriented(pts [][2]float64) [][2]float64 {
+	area := 0.0
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		area += p[0]*q[1] - q[0]*p[1]
+	}
+	if area < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return pts
+}
+
+// rasterize заливає многокутники contours кольором c зі згладжуванням країв. Растеризується лише прямокутник, який
+// охоплює многокутники. Якщо вміст текстури доступний через RGBA, растеризатор малює на ньому напряму, інакше —
+// через маску, див. drawMask.
+func rasterize(t screen.Texture, contours [][][2]float64, c color.Color) {
+	area := image.Rectangle{}
+	for _, pts := range contours {
+		for _, p := range pts {
+			r := image.Rect(int(math.Floor(p[0])), int(math.Floor(p[1])), int(math.Ceil(p[0]))+1, int(math.Ceil(p[1]))+1)
+			area = area.Union(r)
+		}
+	}
+	area = area.Intersect(t.Bounds())
+	if area.Empty() {
+		return
+	}
+
+	z := vector.NewRasterizer(area.Dx(), area.Dy())
+	ox, oy := float64(area.Min.X), float64(area.Min.Y)
+	for _, pts := range contours {
+		if len(pts) < 3 {
+			continue
+		}
+		z.MoveTo(float32(pts[0][0]-ox), float32(pts[0][1]-oy))
+		for _, p := range pts[1:] {
+			z.LineTo(float32(p[0]-ox), float32(p[1]-oy))
+		}
+		z.ClosePath()
+	}
+
+	if dst, ok := t.(interface{ RGBA() *image.RGBA }); ok {
+		z.Draw(dst.RGBA(), area, image.NewUniform(c), image.Point{})
+		return
+	}
+	mask := image.NewAlpha(area)
+	z.Draw(mask, area, image.Opaque, image.Point{})
+	drawMask(t, mask, c)
+}
+
+// drawMask накладає колір c на текстуру з прозорістю маски. Текстури Loop завжди надають RGBA, див. bufferTexture,
+// тож контури й текст на текстурах драйвера малюються в буфер кадру. Для інших текстур пікселі рядка з однаковим
+// покриттям заливаються однією смугою через t.Fill.
+func drawMask(t screen.Texture, mask *image.Alpha, c color.Color) {
+	if dst, ok := t.(interface{ RGBA() *image.RGBA }); ok {
+		draw.DrawMask(dst.RGBA(), mask.Rect, image.NewUniform(c), image.Point{}, mask, mask.Rect.Min, draw.Over)
+		return
+	}
+	cr, cg, cb, ca := c.RGBA()
+	w := mask.Rect.Dx()
+	for y := range mask.Rect.Dy() {
+		row := mask.Pix[y*mask.Stride : y*mask.Stride+w]
+		for x0 := 0; x0 < len(row); {
+			a := row[x0]
+			x1 := x0 + 1
+			for x1 < len(row) && row[x1] == a {
+				x1++
+			}
+			if a != 0 {
+				k := uint32(a) * 0x101
+				cov := color.RGBA64{
+					R: uint16(cr * k / 0xffff), G: uint16(cg * k / 0xffff),
+					B: uint16(cb * k / 0xffff), A: uint16(ca * k / 0xffff),
+				}
+				t.Fill(image.Rect(x0, y, x1, y+1).Add(mask.Rect.Min), cov, draw.Over)
+			}
+			x0 = x1
+		}
+	}
+}
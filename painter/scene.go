@@ -0,0 +1,336 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+
+	"github.com/DmytroHalai/kpi-3/ui"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Координати елементів сцени нормалізовані: (0, 0) — лівий верхній кут полотна, (1, 1) — правий нижній. У пікселі
+// вони переводяться лише під час малювання, тож сцена не залежить від розміру текстури.
+
+// Shape — T-фігура сцени. ID стабільний протягом життя фігури та використовується, щоб адресувати її командами.
+type Shape struct {
+	ID     string
+	X      float64
+	Y      float64
+	Color  color.Color // nil означає колір за замовчуванням
+	Z      int
+	Parent string // група, в якій лежить фігура; координати задані в її системі
+}
+
+// Rectangle — прямокутник на фоні сцени. Прямокутники та фігури мають спільний простір ідентифікаторів і
+// z-індексів: елементи з більшим Z малюються поверх.
+type Rectangle struct {
+	ID      string
+	X1      float64
+	Y1      float64
+	X2      float64
+	Y2      float64
+	Color   color.Color // nil означає чорний
+	Outline bool        // малювати лише контур замість заливки
+	Z       int
+	Parent  string // група, в якій лежить прямокутник; координати задані в її системі
+	Paint   *Paint // градієнт або візерунок замість Color; nil означає суцільний колір
+}
+
+// Scene описує стан зображення, яке формує цикл подій.
+type Scene struct {
+	BgColor    color.Color
+	BgPaint    *Paint // градієнт або візерунок фону замість BgColor; nil означає суцільний колір
+	Rects      []Rectangle
+	Shapes     []Shape
+	Primitives []Primitive
+	Texts      []Text
+	Images     []Image
+	Groups     []Group
+	Tweens     []Tween // анімації фігур, які ще виконуються
+
+	lastID  int      // лічильник для автоматичних ідентифікаторів елементів
+	history *history // попередні стани для Undo та Redo; не копіюється Clone
+}
+
+// DefaultShapeColor — колір фігур, для яких колір не задано.
+var DefaultShapeColor color.Color = color.RGBA{R: 255, G: 255, A: 255}
+
+// OutlineWidth — товщина контуру прямокутників у режимі Outline.
+const OutlineWidth = 3
+
+// Shape повертає індекс фігури з указаним ідентифікатором або -1, якщо такої немає.
+func (s *Scene) Shape(id string) int {
+	for i := range s.Shapes {
+		if s.Shapes[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Rect повертає індекс прямокутника з указаним ідентифікатором або -1, якщо такого немає.
+func (s *Scene) Rect(id string) int {
+	for i := range s.Rects {
+		if s.Rects[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// z повертає вказівник на z-індекс елемента з указаним ідентифікатором.
+func (s *Scene) z(id string) *int {
+	if i := s.Shape(id); i >= 0 {
+		return &s.Shapes[i].Z
+	}
+	if i := s.Rect(id); i >= 0 {
+		return &s.Rects[i].Z
+	}
+	if i := s.Primitive(id); i >= 0 {
+		return &s.Primitives[i].Z
+	}
+	if i := s.Text(id); i >= 0 {
+		return &s.Texts[i].Z
+	}
+	if i := s.Image(id); i >= 0 {
+		return &s.Images[i].Z
+	}
+	if i := s.Group(id); i >= 0 {
+		return &s.Groups[i].Z
+	}
+	return nil
+}
+
+// zRange повертає найменший та найбільший z-індекси елементів сцени.
+func (s *Scene) zRange() (lo, hi int) {
+	first := true
+	visit := func(z int) {
+		if first || z < lo {
+			lo = z
+		}
+		if first || z > hi {
+			hi = z
+		}
+		first = false
+	}
+	for _, r := range s.Rects {
+		visit(r.Z)
+	}
+	for _, sh := range s.Shapes {
+		visit(sh.Z)
+	}
+	for _, p := range s.Primitives {
+		visit(p.Z)
+	}
+	for _, tx := range s.Texts {
+		visit(tx.Z)
+	}
+	for _, im := range s.Images {
+		visit(im.Z)
+	}
+	for _, g := range s.Groups {
+		visit(g.Z)
+	}
+	return lo, hi
+}
+
+// topZ повертає z-індекс, з яким новий елемент опиниться поверх усіх інших.
+func (s *Scene) topZ() int {
+	if len(s.Rects) == 0 && len(s.Shapes) == 0 && len(s.Primitives) == 0 && len(s.Texts) == 0 && len(s.Images) == 0 &&
+		len(s.Groups) == 0 {
+		return 0
+	}
+	_, hi := s.zRange()
+	return hi + 1
+}
+
+// remove видаляє елемент з указаним ідентифікатором, якщо він є. Група видаляється разом з усіма її елементами.
+func (s *Scene) remove(id string) {
+	if i := s.Shape(id); i >= 0 {
+		s.Shapes = append(s.Shapes[:i], s.Shapes[i+1:]...)
+	}
+	if i := s.Rect(id); i >= 0 {
+		s.Rects = append(s.Rects[:i], s.Rects[i+1:]...)
+	}
+	if i := s.Primitive(id); i >= 0 {
+		s.Primitives = append(s.Primitives[:i], s.Primitives[i+1:]...)
+	}
+	if i := s.Text(id); i >= 0 {
+		s.Texts = append(s.Texts[:i], s.Texts[i+1:]...)
+	}
+	if i := s.Image(id); i >= 0 {
+		s.Images = append(s.Images[:i], s.Images[i+1:]...)
+	}
+	if i := s.Group(id); i >= 0 {
+		s.Groups = append(s.Groups[:i], s.Groups[i+1:]...)
+		s.Rects = slices.DeleteFunc(s.Rects, func(r Rectangle) bool { return r.Parent == id })
+		s.Shapes = slices.DeleteFunc(s.Shapes, func(sh Shape) bool { return sh.Parent == id })
+		s.Primitives = slices.DeleteFunc(s.Primitives, func(p Primitive) bool { return p.Parent == id })
+		s.Texts = slices.DeleteFunc(s.Texts, func(tx Text) bool { return tx.Parent == id })
+		s.Images = slices.DeleteFunc(s.Images, func(im Image) bool { return im.Parent == id })
+		var nested []string
+		for _, g := range s.Groups {
+			if g.Parent == id {
+				nested = append(nested, g.ID)
+			}
+		}
+		for _, g := range nested {
+			s.remove(g)
+		}
+	}
+}
+
+// newID генерує ідентифікатор з указаним префіксом, який ще не використовується у сцені.
+func (s *Scene) newID(prefix string) string {
+	for {
+		s.lastID++
+		id := fmt.Sprintf("%s%d", prefix, s.lastID)
+		if s.z(id) == nil {
+			return id
+		}
+	}
+}
+
+// Clone повертає глибоку копію сцени без історії змін.
+func (s *Scene) Clone() Scene {
+	c := *s
+	c.BgPaint = s.BgPaint.clone()
+	c.Rects = append([]Rectangle(nil), s.Rects...)
+	for i := range c.Rects {
+		c.Rects[i].Paint = c.Rects[i].Paint.clone()
+	}
+	c.Shapes = append([]Shape(nil), s.Shapes...)
+	c.Primitives = append([]Primitive(nil), s.Primitives...)
+	for i := range c.Primitives {
+		c.Primitives[i].Params = slices.Clone(c.Primitives[i].Params)
+	}
+	c.Texts = append([]Text(nil), s.Texts...)
+	c.Images = append([]Image(nil), s.Images...)
+	c.Groups = append([]Group(nil), s.Groups...)
+	c.Tweens = append([]Tween(nil), s.Tweens...)
+	c.history = nil
+	return c
+}
+
+// render малює сцену на текстурі. Фон заливається без накладання, тож його прозорість зберігається у текстурі.
+func render(scene *Scene, t screen.Texture) {
+	if scene.BgPaint != nil {
+		drawBackground(t, scene.BgPaint)
+	} else {
+		bgColor := scene.BgColor
+		if bgColor == nil {
+			bgColor = color.RGBA{G: 128, A: 255}
+		}
+		t.Fill(t.Bounds(), bgColor, screen.Src)
+	}
+
+	scene.walk(t.Bounds(), func(n node) {
+		switch {
+		case n.rect != nil:
+			drawRect(t, *n.rect, n.m)
+		case n.shape != nil:
+			drawShape(t, *n.shape, n.m)
+		case n.text != nil:
+			drawText(t, *n.text, n.m)
+		case n.image != nil:
+			drawImage(t, *n.image, n.m)
+		default:
+			drawPrimitive(t, *n.prim, n.m)
+		}
+	})
+}
+
+// drawRect малює прямокутник з перетворенням m його групи.
+func drawRect(t screen.Texture, rect Rectangle, m affine) {
+	c := rect.Color
+	if c == nil {
+		c = color.Black
+	}
+	b := t.Bounds()
+	r := image.Rect(toPx(rect.X1, b.Dx()), toPx(rect.Y1, b.Dy()), toPx(rect.X2, b.Dx()), toPx(rect.Y2, b.Dy()))
+	parts := []image.Rectangle{r}
+	if rect.Outline {
+		w := min(OutlineWidth, r.Dx()/2, r.Dy()/2)
+		parts = []image.Rectangle{
+			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+w),
+			image.Rect(r.Min.X, r.Max.Y-w, r.Max.X, r.Max.Y),
+			image.Rect(r.Min.X, r.Min.Y+w, r.Min.X+w, r.Max.Y-w),
+			image.Rect(r.Max.X-w, r.Min.Y+w, r.Max.X, r.Max.Y-w),
+		}
+	}
+	if rect.Paint != nil {
+		paintRect(t, r, parts, m, rect.Paint)
+		return
+	}
+	op := fillOp(c)
+	for _, part := range parts {
+		fillRect(t, part, m, c, op)
+	}
+}
+
+// drawShape малює T-фігуру з перетворенням m її групи.
+func drawShape(t screen.Texture, shape Shape, m affine) {
+	c := shape.Color
+	if c == nil {
+		c = DefaultShapeColor
+	}
+	top, vert := ui.TShapeRects(shapeGeometry(shape, t.Bounds()))
+	op := fillOp(c)
+	fillRect(t, vert, m, c, op)
+	fillRect(t, top, m, c, op)
+}
+
+// shapeGeometry повертає центр фігури у пікселях полотна b та область, від якої залежить її розмір. Розмір фігури
+// залежить від меншої сторони полотна, щоб за неквадратного полотна вона не розтягувалась.
+func shapeGeometry(shape Shape, b image.Rectangle) (cx, cy int, area image.Rectangle) {
+	side := min(b.Dx(), b.Dy())
+	return toPx(shape.X, b.Dx()), toPx(shape.Y, b.Dy()), image.Rect(0, 0, side, side)
+}
+
+// ShapeAt повертає індекс найвищої фігури, яка покриває точку (x, y) на полотні b, або -1, якщо такої немає.
+// Перетворення груп враховуються.
+func (s *Scene) ShapeAt(x, y float64, b image.Rectangle) int {
+	// Перевіряється центр пікселя, як і під час заливки.
+	px, py := math.Floor(x*float64(b.Dx()))+0.5, math.Floor(y*float64(b.Dy()))+0.5
+	var hit *Shape
+	s.walk(b, func(n node) {
+		if n.shape == nil {
+			return
+		}
+		inv, ok := n.m.invert()
+		if !ok {
+			return
+		}
+		lx, ly := inv.apply(px, py)
+		top, vert := ui.TShapeRects(shapeGeometry(*n.shape, b))
+		if inRect(lx, ly, top) || inRect(lx, ly, vert) {
+			hit = n.shape
+		}
+	})
+	if hit == nil {
+		return -1
+	}
+	return s.Shape(hit.ID)
+}
+
+func inRect(x, y float64, r image.Rectangle) bool {
+	return x >= float64(r.Min.X) && x < float64(r.Max.X) && y >= float64(r.Min.Y) && y < float64(r.Max.Y)
+}
+
+// toPx переводить нормалізовану координату у пікселі для сторони довжиною n.
+func toPx(v float64, n int) int {
+	return int(math.Round(v * float64(n)))
+}
+
+// fillOp обирає режим заливки: непрозорі кольори просто замінюють пікселі, а напівпрозорі накладаються поверх.
+func fillOp(c color.Color) draw.Op {
+	if _, _, _, a := c.RGBA(); a == 0xffff {
+		return screen.Src
+	}
+	return screen.Over
+}
@@ -0,0 +1,282 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+)
+
+// Text — напис сцени. Точка (X, Y) лежить на базовій лінії першого рядка: на його лівому краї, посередині чи на
+// правому краї залежно від Align. Рядки розділяються символом '\n'.
+type Text struct {
+	ID      string
+	X       float64
+	Y       float64
+	Size    float64 // кегль у частках меншої сторони полотна
+	Text    string
+	Color   color.Color // nil означає DefaultTextColor
+	Align   Align
+	Font    string  // назва шрифту з FontNames; порожній рядок означає "regular"
+	Spacing float64 // відстань між базовими лініями рядків у кеглях; 0 означає DefaultLineSpacing
+	Z       int
+	Parent  string // група, в якій лежить напис; координати задані в її системі
+}
+
+// Align — вирівнювання рядків напису відносно точки прив'язки.
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+var alignNames = []string{"left", "center", "right"}
+
+func (a Align) String() string {
+	if a >= 0 && int(a) < len(alignNames) {
+		return alignNames[a]
+	}
+	return fmt.Sprintf("Align(%d)", int(a))
+}
+
+// ParseAlign повертає вирівнювання за назвою left, center або right.
+func ParseAlign(s string) (Align, error) {
+	if i := slices.Index(alignNames, s); i >= 0 {
+		return Align(i), nil
+	}
+	return 0, fmt.Errorf("unknown alignment %q, expected one of %v", s, alignNames)
+}
+
+// DefaultTextColor — колір написів, для яких колір не задано.
+var DefaultTextColor color.Color = color.Black
+
+// DefaultLineSpacing — відстань між базовими лініями рядків у кеглях, якщо її не задано.
+const DefaultLineSpacing = 1.2
+
+// MaxTextSize — найбільший кегль напису в частках меншої сторони полотна.
+const MaxTextSize = 1
+
+// maxTextPixels обмежує розмір гліфа та проміжного зображення напису, щоб величезний кегль, зокрема збільшений
+// масштабом групи, не вичерпав пам'ять.
+const maxTextPixels = 16 << 20
+
+// fontFiles — шрифти Go, доступні написам.
+var fontFiles = map[string][]byte{
+	"regular": goregular.TTF,
+	"bold":    gobold.TTF,
+	"italic":  goitalic.TTF,
+	"mono":    gomono.TTF,
+}
+
+var (
+	fontsMu sync.Mutex
+	fonts   = map[string]*opentype.Font{}
+)
+
+// FontNames повертає назви доступних шрифтів за абеткою.
+func FontNames() []string {
+	names := make([]string, 0, len(fontFiles))
+	for name := range fontFiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// loadFont повертає шрифт з указаною назвою. Файл шрифту розбирається під час першого використання.
+func loadFont(name string) (*opentype.Font, error) {
+	if name == "" {
+		name = "regular"
+	}
+	fontsMu.Lock()
+	defer fontsMu.Unlock()
+	if f, ok := fonts[name]; ok {
+		return f, nil
+	}
+	data, ok := fontFiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown font %q", name)
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	fonts[name] = f
+	return f, nil
+}
+
+// Validate перевіряє кегль, шрифт і вирівнювання напису.
+func (t *Text) Validate() error {
+	if !(t.Size > 0 && t.Size <= MaxTextSize) {
+		return fmt.Errorf("text size must be in (0, %d], got %g", MaxTextSize, t.Size)
+	}
+	if t.Spacing < 0 || math.IsNaN(t.Spacing) || math.IsInf(t.Spacing, 0) {
+		return fmt.Errorf("line spacing must not be negative, got %g", t.Spacing)
+	}
+	if t.Align < AlignLeft || t.Align > AlignRight {
+		return fmt.Errorf("unknown alignment %d", t.Align)
+	}
+	_, err := loadFont(t.Font)
+	return err
+}
+
+// Text повертає індекс напису з указаним ідентифікатором або -1, якщо такого немає.
+func (s *Scene) Text(id string) int {
+	for i := range s.Texts {
+		if s.Texts[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddText додає напис у сцену поверх інших елементів. Якщо ID порожній, напису призначається новий ідентифікатор;
+// якщо елемент з таким ID вже існує, напис займає його місце та z-індекс, а без Parent — і його групу. Напис з
+// невідповідними параметрами не додається.
+type AddText Text
+
+func (op AddText) Do(t screen.Texture, s *Scene) bool {
+	text := Text(op)
+	if text.Validate() != nil {
+		return false
+	}
+	if text.ID == "" {
+		text.ID = s.newID("t")
+	}
+	if text.Parent == "" {
+		if p := s.parent(text.ID); p != nil {
+			text.Parent = *p
+		}
+	}
+	text.Parent = s.validParent(text.ID, text.Parent)
+	if z := s.z(text.ID); z != nil {
+		text.Z = *z
+	} else {
+		text.Z = s.topZ()
+	}
+	if i := s.Text(text.ID); i >= 0 {
+		s.Texts[i] = text
+	} else {
+		s.remove(text.ID)
+		s.Texts = append(s.Texts, text)
+	}
+	render(s, t)
+	return false
+}
+
+// drawText малює напис з перетворенням m його групи. Гліфи растеризуються з урахуванням масштабу групи, тож
+// збільшений напис лишається чітким; повернутий або розтягнутий напис переноситься на полотно з білінійною
+// інтерполяцією.
+func drawText(t screen.Texture, text Text, m affine) {
+	f, err := loadFont(text.Font)
+	if err != nil {
+		return
+	}
+	b := t.Bounds()
+	k := math.Sqrt(math.Abs(m.a*m.d - m.b*m.c))
+	size := text.Size * side(b) * k
+	// Гліф растеризується повністю навіть тоді, коли на полотно потрапляє лише його частина, і займає приблизно
+	// квадрат зі стороною в кегль.
+	if !(size >= 1) || size*size > maxTextPixels {
+		return
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return
+	}
+	defer face.Close()
+
+	spacing := text.Spacing
+	if spacing == 0 {
+		spacing = DefaultLineSpacing
+	}
+	lines := strings.Split(text.Text, "\n")
+	// Зсуви рядків та межі напису в пікселях маски відносно точки прив'язки.
+	origins := make([][2]float64, len(lines))
+	metrics := face.Metrics()
+	x0, x1 := 0.0, 0.0
+	for i, line := range lines {
+		w := float64(font.MeasureString(face, line)) / 64
+		x := 0.0
+		switch text.Align {
+		case AlignCenter:
+			x = -w / 2
+		case AlignRight:
+			x = -w
+		}
+		origins[i] = [2]float64{x, float64(i) * size * spacing}
+		x0, x1 = min(x0, x), max(x1, x+w)
+	}
+	y0 := -float64(metrics.Ascent) / 64
+	y1 := origins[len(lines)-1][1] + float64(metrics.Descent)/64
+	// Курсив та деякі гліфи виходять за межі ширини рядка, тож маска має запас.
+	pad := size / 4
+	local := image.Rect(int(math.Floor(x0-pad)), int(math.Floor(y0-pad)), int(math.Ceil(x1+pad)), int(math.Ceil(y1+pad)))
+	if local.Dx()*local.Dy() > maxTextPixels {
+		return
+	}
+
+	// toCanvas переводить пікселі маски у пікселі полотна.
+	toCanvas := m.mul(translate(text.X*float64(b.Dx()), text.Y*float64(b.Dy()))).mul(affine{a: 1 / k, d: 1 / k})
+	c := text.Color
+	if c == nil {
+		c = DefaultTextColor
+	}
+
+	if toCanvas.axisAligned() && math.Abs(toCanvas.a-1) < 1e-9 && math.Abs(toCanvas.d-1) < 1e-9 {
+		// Без повороту й розтягу гліфи малюються одразу в координатах полотна.
+		ox, oy := toCanvas.e, toCanvas.f
+		area := local.Add(image.Pt(int(math.Floor(ox)), int(math.Floor(oy)))).Inset(-1).Intersect(b)
+		if area.Empty() {
+			return
+		}
+		mask := image.NewAlpha(area)
+		drawLines(mask, face, lines, origins, ox, oy)
+		drawMask(t, mask, c)
+		return
+	}
+
+	src := image.NewAlpha(local)
+	drawLines(src, face, lines, origins, 0, 0)
+	area := image.Rectangle{}
+	for _, p := range [4]image.Point{local.Min, {local.Max.X, local.Min.Y}, local.Max, {local.Min.X, local.Max.Y}} {
+		x, y := toCanvas.apply(float64(p.X), float64(p.Y))
+		area = area.Union(image.Rect(int(math.Floor(x)), int(math.Floor(y)), int(math.Ceil(x))+1, int(math.Ceil(y))+1))
+	}
+	area = area.Intersect(b)
+	if area.Empty() {
+		return
+	}
+	mask := image.NewAlpha(area)
+	s2d := f64.Aff3{toCanvas.a, toCanvas.c, toCanvas.e, toCanvas.b, toCanvas.d, toCanvas.f}
+	draw.BiLinear.Transform(mask, s2d, src, src.Rect, draw.Over, nil)
+	drawMask(t, mask, c)
+}
+
+// drawLines малює рядки напису в маску, зсунувши їх на (ox, oy).
+func drawLines(mask *image.Alpha, face font.Face, lines []string, origins [][2]float64, ox, oy float64) {
+	d := font.Drawer{Dst: mask, Src: image.Opaque, Face: face}
+	for i, line := range lines {
+		d.Dot = fixed.Point26_6{
+			X: fixed.Int26_6(math.Round((origins[i][0] + ox) * 64)),
+			Y: fixed.Int26_6(math.Round((origins[i][1] + oy) * 64)),
+		}
+		d.DrawString(line)
+	}
+}
@@ -0,0 +1,123 @@
+package painter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/DmytroHalai/kpi-3/ui/headless"
+)
+
+// inkBounds повертає прямокутник, який охоплює темні пікселі зображення.
+func inkBounds(img *image.RGBA) image.Rectangle {
+	var r image.Rectangle
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.RGBAAt(x, y).R < 128 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestRender_Text(t *testing.T) {
+	tx, _ := headless.Screen{}.NewTexture(image.Pt(400, 400))
+	img := tx.(*headless.Texture).RGBA()
+	text := func(ops ...Operation) image.Rectangle {
+		renderPrimitives(tx, ops...)
+		return inkBounds(img)
+	}
+
+	left := text(AddText{X: 0.5, Y: 0.5, Size: 0.1, Text: "Hello"})
+	if left.Empty() || left.Min.X < 198 || left.Max.Y > 201 || left.Min.Y < 160 {
+		t.Errorf("left-aligned text should start at the anchor above the baseline, got %v", left)
+	}
+	right := text(AddText{X: 0.5, Y: 0.5, Size: 0.1, Text: "Hello", Align: AlignRight})
+	if right.Max.X > 202 || right.Dx() != left.Dx() {
+		t.Errorf("right-aligned text should end at the anchor, got %v (left %v)", right, left)
+	}
+	center := text(AddText{X: 0.5, Y: 0.5, Size: 0.1, Text: "Hello", Align: AlignCenter})
+	if d := (center.Min.X + center.Max.X) / 2; d < 197 || d > 203 {
+		t.Errorf("centered text should be centered on the anchor, got %v", center)
+	}
+
+	lines := text(AddText{X: 0.1, Y: 0.2, Size: 0.1, Text: "A\nB\nC", Spacing: 1.5})
+	if h := lines.Max.Y - lines.Min.Y; h < 150 || h > 175 {
+		t.Errorf("expected three lines 60px apart, got %v", lines)
+	}
+	bold := text(AddText{X: 0.1, Y: 0.5, Size: 0.1, Text: "Hello", Font: "bold", Color: color.RGBA{A: 255}})
+	if bold.Dx() <= left.Dx() {
+		t.Errorf("bold text should be wider than regular, got %v and %v", bold, left)
+	}
+
+	rotated := text(
+		AddGroup{ID: "g"}, AddText{X: 0.1, Y: 0.5, Size: 0.1, Text: "Hello", Parent: "g"},
+		PivotGroup{ID: "g", X: 0.5, Y: 0.5}, RotateGroup{ID: "g", Angle: 90},
+	)
+	if rotated.Dy() <= rotated.Dx() || rotated.Dy() < left.Dx()-5 {
+		t.Errorf("rotated text should be vertical, got %v", rotated)
+	}
+	scaled := text(AddGroup{ID: "g"}, AddText{X: 0.1, Y: 0.5, Size: 0.05, Text: "Hello", Parent: "g"}, ScaleGroup{ID: "g", X: 2, Y: 2})
+	if d := scaled.Dx() - left.Dx(); d < -3 || d > 3 {
+		t.Errorf("text scaled twice should match the double size, got %v and %v", scaled, left)
+	}
+
+	// Гліфи, збільшені масштабом групи понад maxTextPixels, не растеризуються навіть без повороту.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	huge := text(AddGroup{ID: "g"}, AddText{Y: 0.002, Size: 1, Text: "W", Parent: "g"}, ScaleGroup{ID: "g", X: 100, Y: 100})
+	runtime.ReadMemStats(&after)
+	if !huge.Empty() {
+		t.Errorf("huge text should not be drawn, got %v", huge)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > maxTextPixels {
+		t.Errorf("huge text allocated %d bytes", n)
+	}
+}
+
+func TestText_SceneModel(t *testing.T) {
+	var s Scene
+	applyOps(&s,
+		AddText{ID: "t", X: 0.1, Y: 0.1, Size: 0.05, Text: "note"},
+		AddText{ID: "bad", Size: 0.05, Text: "x", Font: "comic"},
+		AddText{ID: "zero", Text: "x"},
+		Record{}, MoveShapes{ID: "t", X: 0.1, Y: 0.2, Relative: true}, Recolor{ID: "t", Color: color.NRGBA{R: 255, A: 255}},
+	)
+	if len(s.Texts) != 1 || !near(s.Texts[0].X, 0.2) || !near(s.Texts[0].Y, 0.3) || s.Texts[0].Color != (color.NRGBA{R: 255, A: 255}) {
+		t.Fatalf("unexpected texts %+v", s.Texts)
+	}
+	applyOps(&s, Record{}, Reset{})
+	if len(s.Texts) != 0 {
+		t.Fatalf("reset should remove texts: %+v", s.Texts)
+	}
+	applyOps(&s, Undo{})
+	if len(s.Texts) != 1 || !near(s.Texts[0].X, 0.2) {
+		t.Errorf("undo should restore the moved text: %+v", s.Texts)
+	}
+
+	var buf bytes.Buffer
+	applyOps(&s, AddText{ID: "c", X: 0.5, Y: 0.5, Size: 0.1, Text: "a\nb", Align: AlignCenter, Font: "mono", Spacing: 2})
+	if err := EncodeScene(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeScene(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Texts, s.Texts) {
+		t.Errorf("expected %+v, got %+v", s.Texts, got.Texts)
+	}
+	for _, data := range []string{
+		`{"version": 4, "texts": [{"id": "a", "size": 0.1, "text": "x", "align": "justify"}]}`,
+		`{"version": 4, "texts": [{"id": "a", "size": 0, "text": "x"}]}`,
+		`{"version": 4, "texts": [{"id": "a", "size": 20, "text": "x"}]}`,
+	} {
+		if _, err := DecodeScene(bytes.NewBufferString(data)); err == nil {
+			t.Errorf("%s: expected error", data)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package headless містить реалізацію screen.Screen, яка не потребує дисплея: буфери та текстури зберігаються
+// у пам'яті як image.RGBA, тому кожен сформований кадр можна прочитати як звичайне зображення.
+package headless
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// ErrNoWindow повертається при спробі створити вікно у режимі без дисплея.
+var ErrNoWindow = errors.New("headless: windows are not supported")
+
+// Screen реалізує screen.Screen поверх image.RGBA.
+type Screen struct{}
+
+func (Screen) NewBuffer(size image.Point) (screen.Buffer, error) {
+	return &Buffer{rgba: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+func (Screen) NewTexture(size image.Point) (screen.Texture, error) {
+	return &Texture{rgba: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+func (Screen) NewWindow(opts *screen.NewWindowOptions) (screen.Window, error) {
+	return nil, ErrNoWindow
+}
+
+// Buffer реалізує screen.Buffer.
+type Buffer struct {
+	rgba *image.RGBA
+}
+
+func (b *Buffer) Release()                {}
+func (b *Buffer) Size() image.Point       { return b.rgba.Rect.Size() }
+func (b *Buffer) Bounds() image.Rectangle { return b.rgba.Rect }
+func (b *Buffer) RGBA() *image.RGBA       { return b.rgba }
+
+// Texture реалізує screen.Texture. На відміну від текстур віконного драйвера, її вміст доступний через RGBA.
+type Texture struct {
+	rgba *image.RGBA
+}
+
+func (t *Texture) Release()                {}
+func (t *Texture) Size() image.Point       { return t.rgba.Rect.Size() }
+func (t *Texture) Bounds() image.Rectangle { return t.rgba.Rect }
+
+// RGBA повертає зображення, у яке малює текстура.
+func (t *Texture) RGBA() *image.RGBA { return t.rgba }
+
+func (t *Texture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	sr = sr.Intersect(src.Bounds())
+	dr := image.Rectangle{Min: dp, Max: dp.Add(sr.Size())}
+	draw.Draw(t.rgba, dr, src.RGBA(), sr.Min, draw.Src)
+}
+
+func (t *Texture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(t.rgba, dr, image.NewUniform(src), image.Point{}, op)
+}
+
+// Recorder отримує кадри як painter.Receiver і зберігає копію останнього з них. Якщо задано Next, кадр передається
+// йому далі. Щоб записувати кадри, сформовані на екрані віконного драйвера, цикл подій запускають на екрані Wrap.
+type Recorder struct {
+	Next interface{ Update(t screen.Texture) }
+
+	mu    sync.Mutex
+	frame *image.RGBA
+}
+
+// Update копіює вміст текстури. Текстури, вміст яких прочитати неможливо, не записуються. Текстура екрана Wrap
+// передається в Next без обгортки, щоб її можна було показати у вікні драйвера.
+func (r *Recorder) Update(t screen.Texture) {
+	var src *image.RGBA
+	switch t := t.(type) {
+	case *mirrorTexture:
+		src = t.copy
+	case interface{ RGBA() *image.RGBA }:
+		src = t.RGBA()
+	}
+	if src != nil {
+		r.mu.Lock()
+		r.frame = CloneRGBA(src, r.frame)
+		r.mu.Unlock()
+	}
+	if m, ok := t.(*mirrorTexture); ok {
+		t = m.Texture
+	}
+	if r.Next != nil {
+		r.Next.Update(t)
+	}
+}
+
+// Wrap повертає екран, текстури якого малюють у текстури s і водночас у копії в пам'яті, з яких Update записує
+// кадри. Вміст текстур драйвера прочитати неможливо, тож інакше кадри вікна не записуються.
+func (r *Recorder) Wrap(s screen.Screen) screen.Screen {
+	return mirrorScreen{s}
+}
+
+type mirrorScreen struct {
+	screen.Screen
+}
+
+func (s mirrorScreen) NewTexture(size image.Point) (screen.Texture, error) {
+	t, err := s.Screen.NewTexture(size)
+	if err != nil {
+		return nil, err
+	}
+	return &mirrorTexture{Texture: t, copy: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+// mirrorTexture повторює малювання у текстурі драйвера в зображенні copy. Метод RGBA вона навмисно не надає: тоді
+// painter малював би лише в копію, а не на екран.
+type mirrorTexture struct {
+	screen.Texture
+	copy *image.RGBA
+}
+
+func (t *mirrorTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	t.Texture.Upload(dp, src, sr)
+	sr = sr.Intersect(src.Bounds())
+	draw.Draw(t.copy, image.Rectangle{Min: dp, Max: dp.Add(sr.Size())}, src.RGBA(), sr.Min, draw.Src)
+}
+
+func (t *mirrorTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	t.Texture.Fill(dr, src, op)
+	draw.Draw(t.copy, dr, image.NewUniform(src), image.Point{}, op)
+}
+
+// Frame повертає копію останнього отриманого кадру або nil, якщо кадрів ще не було.
+func (r *Recorder) Frame() *image.RGBA {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.frame == nil {
+		return nil
+	}
+	return CloneRGBA(r.frame, nil)
+}
+
+// CloneRGBA копіює src у dst, перевикористовуючи пам'ять dst, якщо її розмір збігається.
+func CloneRGBA(src, dst *image.RGBA) *image.RGBA {
+	if dst == nil || dst.Rect != src.Rect {
+		dst = image.NewRGBA(src.Rect)
+	}
+	draw.Draw(dst, dst.Rect, src, src.Rect.Min, draw.Src)
+	return dst
+}
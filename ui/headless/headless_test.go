@@ -0,0 +1,84 @@
+package headless
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+func TestTexture_FillAndUpload(t *testing.T) {
+	var s Screen
+	tx, _ := s.NewTexture(image.Pt(10, 10))
+	tx.Fill(tx.Bounds(), color.White, draw.Src)
+
+	b, _ := s.NewBuffer(image.Pt(2, 2))
+	draw.Draw(b.RGBA(), b.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	tx.Upload(image.Pt(4, 4), b, b.Bounds())
+
+	img := tx.(*Texture).RGBA()
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("expected white at (0, 0), got %v", got)
+	}
+	if got := img.RGBAAt(5, 5); got != (color.RGBA{A: 255}) {
+		t.Errorf("expected black at (5, 5), got %v", got)
+	}
+}
+
+func TestRecorder_KeepsCopy(t *testing.T) {
+	var (
+		s   Screen
+		rec Recorder
+	)
+	if rec.Frame() != nil {
+		t.Fatal("expected no frame before the first update")
+	}
+
+	tx, _ := s.NewTexture(image.Pt(4, 4))
+	tx.Fill(tx.Bounds(), color.White, draw.Src)
+	rec.Update(tx)
+	tx.Fill(tx.Bounds(), color.Black, draw.Src)
+
+	frame := rec.Frame()
+	if frame == nil {
+		t.Fatal("frame was not recorded")
+	}
+	if got := frame.RGBAAt(1, 1); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("recorded frame changed together with the texture: %v", got)
+	}
+}
+
+type updateFunc func(t screen.Texture)
+
+func (f updateFunc) Update(t screen.Texture) { f(t) }
+
+func TestRecorder_Wrap(t *testing.T) {
+	var (
+		rec  Recorder
+		next screen.Texture
+	)
+	rec.Next = updateFunc(func(t screen.Texture) { next = t })
+	s := rec.Wrap(Screen{})
+	tx, _ := s.NewTexture(image.Pt(4, 4))
+	if _, ok := tx.(interface{ RGBA() *image.RGBA }); ok {
+		t.Fatal("wrapped texture must be drawn through Fill and Upload")
+	}
+	tx.Fill(tx.Bounds(), color.White, draw.Src)
+	b, _ := s.NewBuffer(image.Pt(1, 1))
+	b.RGBA().Set(0, 0, color.Black)
+	tx.Upload(image.Pt(2, 2), b, b.Bounds())
+	rec.Update(tx)
+
+	inner, ok := next.(*Texture)
+	if !ok {
+		t.Fatalf("Next should get the screen texture, got %T", next)
+	}
+	frame := rec.Frame()
+	for _, img := range []*image.RGBA{inner.RGBA(), frame} {
+		if img.RGBAAt(0, 0) != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) || img.RGBAAt(2, 2) != (color.RGBA{A: 255}) {
+			t.Errorf("unexpected frame: %v %v", img.RGBAAt(0, 0), img.RGBAAt(2, 2))
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/paint"
+)
+
+// Shortcut — клавіша разом з модифікаторами, які потрібно утримувати.
+type Shortcut struct {
+	Code      key.Code
+	Modifiers key.Modifiers
+}
+
+var modifierNames = []struct {
+	name string
+	mod  key.Modifiers
+}{
+	{"Ctrl", key.ModControl},
+	{"Alt", key.ModAlt},
+	{"Shift", key.ModShift},
+	{"Meta", key.ModMeta},
+}
+
+var keyNames = map[string]key.Code{
+	"Left": key.CodeLeftArrow, "Right": key.CodeRightArrow, "Up": key.CodeUpArrow, "Down": key.CodeDownArrow,
+	"Space": key.CodeSpacebar, "Enter": key.CodeReturnEnter, "Tab": key.CodeTab, "Backspace": key.CodeDeleteBackspace,
+	"Delete": key.CodeDeleteForward, "Home": key.CodeHome, "End": key.CodeEnd,
+	"PageUp": key.CodePageUp, "PageDown": key.CodePageDown,
+	"-": key.CodeHyphenMinus, "=": key.CodeEqualSign, "/": key.CodeSlash,
+}
+
+func init() {
+	for c := 'A'; c <= 'Z'; c++ {
+		keyNames[string(c)] = key.CodeA + key.Code(c-'A')
+	}
+	keyNames["0"] = key.Code0
+	for c := '1'; c <= '9'; c++ {
+		keyNames[string(c)] = key.Code1 + key.Code(c-'1')
+	}
+	for i := 1; i <= 12; i++ {
+		keyNames[fmt.Sprintf("F%d", i)] = key.CodeF1 + key.Code(i-1)
+	}
+}
+
+// ParseShortcut розбирає комбінацію клавіш на кшталт "Ctrl+Shift+Z", "F1" або "Left". Регістр не враховується.
+func ParseShortcut(s string) (Shortcut, error) {
+	parts := strings.Split(s, "+")
+	var sc Shortcut
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if i < len(parts)-1 {
+			mod, ok := modifierByName(p)
+			if !ok {
+				return Shortcut{}, fmt.Errorf("unknown modifier %q in shortcut %q", p, s)
+			}
+			sc.Modifiers |= mod
+			continue
+		}
+		code, ok := keyByName(p)
+		if !ok {
+			return Shortcut{}, fmt.Errorf("unknown key %q in shortcut %q", p, s)
+		}
+		sc.Code = code
+	}
+	return sc, nil
+}
+
+func modifierByName(name string) (key.Modifiers, bool) {
+	for _, m := range modifierNames {
+		if strings.EqualFold(m.name, name) {
+			return m.mod, true
+		}
+	}
+	return 0, false
+}
+
+func keyByName(name string) (key.Code, bool) {
+	for n, code := range keyNames {
+		if strings.EqualFold(n, name) {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+func (sc Shortcut) String() string {
+	var b strings.Builder
+	for _, m := range modifierNames {
+		if sc.Modifiers&m.mod != 0 {
+			b.WriteString(m.name)
+			b.WriteByte('+')
+		}
+	}
+	for n, code := range keyNames {
+		if code == sc.Code {
+			b.WriteString(n)
+			return b.String()
+		}
+	}
+	b.WriteString(strings.TrimPrefix(sc.Code.String(), "Code"))
+	return b.String()
+}
+
+// Matches повідомляє, чи відповідає натискання клавіші комбінації.
+func (sc Shortcut) Matches(e key.Event) bool {
+	return e.Code == sc.Code && e.Modifiers == sc.Modifiers
+}
+
+// Binding пов'язує комбінацію клавіш з дією. Action викликається в горутині вікна, тож має швидко завершуватись;
+// зміни сцени варто лише додавати у чергу циклу подій.
+type Binding struct {
+	Name     string // назва для налаштування через Rebind
+	Shortcut Shortcut
+	Help     string // опис для довідки
+	Action   func()
+}
+
+// Rebind змінює комбінації клавіш за описом у форматі "name=Shortcut,name=Shortcut", наприклад "undo=Ctrl+U".
+// Якщо дію з такою назвою прив'язано до кількох комбінацій, усі вони замінюються однією.
+func Rebind(bindings []Binding, spec string) ([]Binding, error) {
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name, keys, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("expected name=shortcut, got %q", item)
+		}
+		sc, err := ParseShortcut(keys)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		out := bindings[:0:0]
+		for _, b := range bindings {
+			if b.Name != name {
+				out = append(out, b)
+				continue
+			}
+			if !found {
+				b.Shortcut = sc
+				out = append(out, b)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown key binding %q", name)
+		}
+		bindings = out
+	}
+	return bindings, nil
+}
+
+// handleKey виконує дію першої прив'язки, якій відповідає натискання. Утримання клавіші повторює дію.
+func (pw *Visualizer) handleKey(e key.Event) {
+	if e.Direction == key.DirRelease {
+		return
+	}
+	for _, b := range pw.Bindings {
+		if b.Shortcut.Matches(e) {
+			b.Action()
+			return
+		}
+	}
+}
+
+// ToggleHelp показує або ховає довідку зі списком прив'язок клавіш. Метод призначений для використання як Action.
+func (pw *Visualizer) ToggleHelp() {
+	pw.help = !pw.help
+	pw.w.Send(paint.Event{})
+}
+
+// helpLines повертає рядки довідки: комбінацію клавіш та опис дії.
+func helpLines(bindings []Binding) []string {
+	width := 0
+	for _, b := range bindings {
+		width = max(width, len(b.Shortcut.String()))
+	}
+	lines := make([]string, len(bindings))
+	for i, b := range bindings {
+		lines[i] = fmt.Sprintf("%-*s  %s", width, b.Shortcut, b.Help)
+	}
+	return lines
+}
+
+// drawHelp малює довідку поверх вмісту вікна. Текст рендериться у буфер, тож зображення довідки створюється
+// заново лише тоді, коли її показують.
+func (pw *Visualizer) drawHelp() {
+	if pw.helpTex == nil {
+		img := renderHelp(helpLines(pw.Bindings))
+		buf, err := pw.s.NewBuffer(img.Rect.Size())
+		if err != nil {
+			return
+		}
+		defer buf.Release()
+		draw.Draw(buf.RGBA(), buf.Bounds(), img, image.Point{}, draw.Src)
+		if pw.helpTex, err = pw.s.NewTexture(img.Rect.Size()); err != nil {
+			return
+		}
+		pw.helpTex.Upload(image.Point{}, buf, buf.Bounds())
+	}
+	size := pw.helpTex.Size()
+	dst := image.Rectangle{Max: image.Pt(pw.px(float64(size.X)), pw.px(float64(size.Y)))}.Add(image.Pt(pw.px(10), pw.px(10)))
+	pw.w.Scale(dst, pw.helpTex, pw.helpTex.Bounds(), draw.Over, nil)
+}
+
+func (pw *Visualizer) releaseHelp() {
+	if pw.helpTex != nil {
+		pw.helpTex.Release()
+		pw.helpTex = nil
+	}
+}
+
+const helpPadding = 8
+
+// renderHelp малює рядки довідки на напівпрозорій підкладці.
+func renderHelp(lines []string) *image.RGBA {
+	face := basicfont.Face7x13
+	width := 0
+	for _, l := range lines {
+		width = max(width, font.MeasureString(face, l).Ceil())
+	}
+	height := len(lines) * face.Height
+	img := image.NewRGBA(image.Rect(0, 0, width+2*helpPadding, height+2*helpPadding))
+	draw.Draw(img, img.Rect, image.NewUniform(color.NRGBA{A: 200}), image.Point{}, draw.Src)
+	d := font.Drawer{Dst: img, Src: image.White, Face: face}
+	for i, l := range lines {
+		d.Dot = fixed.P(helpPadding, helpPadding+i*face.Height+face.Ascent)
+		d.DrawString(l)
+	}
+	return img
+}
@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"testing"
+
+	"golang.org/x/mobile/event/key"
+)
+
+func TestParseShortcut(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Shortcut
+		str  string
+	}{
+		{"Ctrl+Z", Shortcut{key.CodeZ, key.ModControl}, "Ctrl+Z"},
+		{"shift+ctrl+z", Shortcut{key.CodeZ, key.ModControl | key.ModShift}, "Ctrl+Shift+Z"},
+		{"F1", Shortcut{key.CodeF1, 0}, "F1"},
+		{"Alt + Left", Shortcut{key.CodeLeftArrow, key.ModAlt}, "Alt+Left"},
+		{"7", Shortcut{key.Code7, 0}, "7"},
+	} {
+		got, err := ParseShortcut(tc.in)
+		if err != nil {
+			t.Errorf("ParseShortcut(%q): %s", tc.in, err)
+			continue
+		}
+		if got != tc.want || got.String() != tc.str {
+			t.Errorf("ParseShortcut(%q) = %v (%s), want %v (%s)", tc.in, got, got, tc.want, tc.str)
+		}
+	}
+	for _, in := range []string{"", "Ctrl+", "Hyper+A", "Ctrl+Nope", "A+B"} {
+		if _, err := ParseShortcut(in); err == nil {
+			t.Errorf("ParseShortcut(%q): expected an error", in)
+		}
+	}
+}
+
+func TestShortcut_Matches(t *testing.T) {
+	sc := Shortcut{key.CodeZ, key.ModControl}
+	if !sc.Matches(key.Event{Code: key.CodeZ, Modifiers: key.ModControl}) {
+		t.Error("expected a match")
+	}
+	if sc.Matches(key.Event{Code: key.CodeZ, Modifiers: key.ModControl | key.ModShift}) {
+		t.Error("extra modifiers should not match")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	bindings := []Binding{
+		{Name: "undo", Shortcut: Shortcut{key.CodeZ, key.ModControl}},
+		{Name: "redo", Shortcut: Shortcut{key.CodeY, key.ModControl}},
+		{Name: "redo", Shortcut: Shortcut{key.CodeZ, key.ModControl | key.ModShift}},
+	}
+	got, err := Rebind(bindings, "undo=U, redo=Ctrl+R")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Shortcut{{key.CodeU, 0}, {key.CodeR, key.ModControl}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d bindings, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Shortcut != want[i] {
+			t.Errorf("binding %d: got %v, want %v", i, got[i].Shortcut, want[i])
+		}
+	}
+	if bindings[2].Name != "redo" {
+		t.Error("Rebind must not modify its argument")
+	}
+
+	for _, spec := range []string{"undo", "nope=A", "undo=Ctrl+?"} {
+		if _, err := Rebind(bindings, spec); err == nil {
+			t.Errorf("Rebind(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestRenderHelp(t *testing.T) {
+	lines := helpLines([]Binding{
+		{Shortcut: Shortcut{key.CodeF1, 0}, Help: "help"},
+		{Shortcut: Shortcut{key.CodeZ, key.ModControl}, Help: "undo"},
+	})
+	if lines[0] != "F1      help" || lines[1] != "Ctrl+Z  undo" {
+		t.Errorf("unexpected help lines: %q", lines)
+	}
+	img := renderHelp(lines)
+	if img.Rect.Dx() <= 2*helpPadding || img.Rect.Dy() != 2*13+2*helpPadding {
+		t.Errorf("unexpected help size: %v", img.Rect)
+	}
+}
@@ -16,17 +16,43 @@ import (
 	"golang.org/x/mobile/event/size"
 )
 
+// PointerEvent — подія миші в нормалізованих координатах кадру: (0, 0) — лівий верхній кут, (1, 1) — правий нижній.
+type PointerEvent struct {
+	X         float64
+	Y         float64
+	Button    mouse.Button
+	Direction mouse.Direction
+	Modifiers key.Modifiers
+}
+
 type Visualizer struct {
 	Title         string
 	Debug         bool
 	OnScreenReady func(s screen.Screen)
-
+	// OnResize викликається зі зміною розміру частини вікна, яку займає кадр, у пікселях пристрою, щоб кадри можна
+	// було формувати одразу в цьому розмірі замість масштабування.
+	OnResize func(size image.Point)
+	// KeepAspect зберігає пропорції кадру: він вписується у вікно з чорними смугами замість розтягування.
+	KeepAspect bool
+	// Aspect — пропорції кадру, які зберігає KeepAspect. Якщо їх не задано, беруться пропорції отриманого кадру.
+	Aspect image.Point
+	// OnPointer отримує події миші в координатах кадру, коли у вікні вже є кадр. Натискання поза кадром
+	// ігноруються.
+	OnPointer func(e PointerEvent)
+	// Bindings — прив'язки клавіш до дій. Escape завжди закриває вікно.
+	Bindings []Binding
+
+	s    screen.Screen
 	w    screen.Window
 	tx   chan screen.Texture
 	done chan struct{}
 
-	sz  size.Event
-	pos image.Rectangle
+	sz     size.Event
+	canvas image.Point // розмір кадру, про який востаннє повідомлено OnResize
+	pos    image.Rectangle
+
+	help    bool           // показувати довідку з прив'язками клавіш
+	helpTex screen.Texture // зображення довідки, створюється при першому показі
 }
 
 func (pw *Visualizer) Main() {
@@ -38,7 +64,11 @@ func (pw *Visualizer) Main() {
 }
 
 func (pw *Visualizer) Update(t screen.Texture) {
-	pw.tx <- t
+	select {
+	case pw.tx <- t:
+	case <-pw.done:
+		// Вікно вже закрито, кадр нікому показувати.
+	}
 }
 
 func (pw *Visualizer) run(s screen.Screen) {
@@ -51,10 +81,12 @@ func (pw *Visualizer) run(s screen.Screen) {
 		log.Fatal("Failed to initialize the app window:", err)
 	}
 	defer func() {
+		pw.releaseHelp()
 		w.Release()
 		close(pw.done)
 	}()
 
+	pw.s = s
 	if pw.OnScreenReady != nil {
 		pw.OnScreenReady(s)
 	}
@@ -110,15 +142,29 @@ func (pw *Visualizer) handleEvent(e any, t screen.Texture) {
 	switch e := e.(type) {
 
 	case size.Event:
-		if pw.sz == (size.Event{}) {
-			pw.sz = e
+		pw.sz = e
+		if c := pw.canvasSize(); c != pw.canvas && c.X > 0 && c.Y > 0 && pw.OnResize != nil {
+			pw.canvas = c
+			pw.OnResize(c)
 		}
+		// Поки новий кадр не готовий, поточний перемальовується під новий розмір вікна.
+		pw.w.Send(paint.Event{})
 		return
 
 	case error:
 		log.Printf("ERROR: %s", e)
 
+	case key.Event:
+		pw.handleKey(e)
+
 	case mouse.Event:
+		if t != nil && pw.OnPointer != nil {
+			x, y, inside := framePoint(pw.frameRect(t), e.X, e.Y)
+			if inside || e.Direction != mouse.DirPress {
+				pw.OnPointer(PointerEvent{X: x, Y: y, Button: e.Button, Direction: e.Direction, Modifiers: e.Modifiers})
+			}
+			return
+		}
 		if e.Button == mouse.ButtonLeft && e.Direction == mouse.DirPress {
 			pw.pos.Min.X = int(e.X)
 			pw.pos.Min.Y = int(e.Y)
@@ -131,14 +177,21 @@ func (pw *Visualizer) handleEvent(e any, t screen.Texture) {
 			pw.drawDefaultUI()
 		} else {
 			// Використання текстури отриманої через виклик Update.
-			pw.w.Scale(pw.sz.Bounds(), t, t.Bounds(), draw.Src, nil)
+			dst := pw.frameRect(t)
+			if dst != pw.sz.Bounds() {
+				pw.w.Fill(pw.sz.Bounds(), color.Black, draw.Src)
+			}
+			pw.w.Scale(dst, t, t.Bounds(), draw.Src, nil)
+		}
+		if pw.help {
+			pw.drawHelp()
 		}
 		pw.w.Publish()
 	}
 }
 
 func (pw *Visualizer) drawDefaultUI() {
-	const borderThickness = 10
+	borderThickness := pw.px(10)
 
 	bgColor := color.RGBA{G: 128, A: 255}
 	shapeColor := color.RGBA{R: 255, G: 255, A: 255}
@@ -150,6 +203,60 @@ func (pw *Visualizer) drawDefaultUI() {
 	pw.drawBorder(borderThickness, borderColor)
 }
 
+// px переводить розмір у точках у пікселі з урахуванням щільності екрана.
+func (pw *Visualizer) px(pt float64) int {
+	ppp := float64(pw.sz.PixelsPerPt)
+	if ppp <= 0 {
+		ppp = 1
+	}
+	return max(1, int(pt*ppp+0.5))
+}
+
+// frameRect повертає частину вікна, у якій відображається кадр t.
+func (pw *Visualizer) frameRect(t screen.Texture) image.Rectangle {
+	if !pw.KeepAspect {
+		return pw.sz.Bounds()
+	}
+	if pw.Aspect.X > 0 && pw.Aspect.Y > 0 {
+		return Letterbox(pw.sz.Bounds(), pw.Aspect)
+	}
+	return Letterbox(pw.sz.Bounds(), t.Size())
+}
+
+// canvasSize повертає розмір кадру в пікселях пристрою, за якого він відображається без масштабування: усе вікно
+// або, якщо KeepAspect, вписана в нього частина з пропорціями Aspect.
+func (pw *Visualizer) canvasSize() image.Point {
+	if pw.KeepAspect && pw.Aspect.X > 0 && pw.Aspect.Y > 0 {
+		return Letterbox(pw.sz.Bounds(), pw.Aspect).Size()
+	}
+	return pw.sz.Size()
+}
+
+// framePoint переводить точку вікна в нормалізовані координати кадру, який займає dst.
+func framePoint(dst image.Rectangle, px, py float32) (x, y float64, inside bool) {
+	if dst.Empty() {
+		return 0, 0, false
+	}
+	x = (float64(px) - float64(dst.Min.X)) / float64(dst.Dx())
+	y = (float64(py) - float64(dst.Min.Y)) / float64(dst.Dy())
+	return x, y, x >= 0 && x < 1 && y >= 0 && y < 1
+}
+
+// Letterbox повертає найбільший прямокутник з пропорціями size, який вписується в dst по центру.
+func Letterbox(dst image.Rectangle, size image.Point) image.Rectangle {
+	if size.X <= 0 || size.Y <= 0 || dst.Empty() {
+		return dst
+	}
+	w, h := dst.Dx(), dst.Dy()
+	if w*size.Y > h*size.X {
+		w = h * size.X / size.Y
+	} else {
+		h = w * size.Y / size.X
+	}
+	origin := dst.Min.Add(image.Pt((dst.Dx()-w)/2, (dst.Dy()-h)/2))
+	return image.Rectangle{Min: origin, Max: origin.Add(image.Pt(w, h))}
+}
+
 func (pw *Visualizer) drawBackground(bg color.RGBA) {
 	pw.w.Fill(pw.sz.Bounds(), bg, draw.Src)
 }
@@ -194,7 +301,16 @@ func (pw *Visualizer) drawBorder(thickness int, borderColor color.Color) {
 	}
 }
 
-func DrawTShape(t screen.Texture, cx, cy int, area image.Rectangle, shapeColor color.Color) {
+// DrawTShape малює T-фігуру з центром у (cx, cy), розмір якої залежить від area. Частини фігури не перекриваються,
+// тому напівпрозорий колір з draw.Over накладається рівномірно.
+func DrawTShape(t screen.Texture, cx, cy int, area image.Rectangle, shapeColor color.Color, op draw.Op) {
+	topRect, vertRect := TShapeRects(cx, cy, area)
+	t.Fill(vertRect, shapeColor, op)
+	t.Fill(topRect, shapeColor, op)
+}
+
+// TShapeRects повертає горизонтальну та вертикальну частини T-фігури, яку малює DrawTShape.
+func TShapeRects(cx, cy int, area image.Rectangle) (top, vert image.Rectangle) {
 	maxWidth := area.Dx() / 2
 	maxHeight := area.Dy() / 2
 
@@ -203,20 +319,18 @@ func DrawTShape(t screen.Texture, cx, cy int, area image.Rectangle, shapeColor c
 	tWidthVert := int(float64(maxWidth) * 0.2)
 	tHeightVert := int(float64(maxHeight) * 0.7)
 
-	topRect := image.Rect(
+	top = image.Rect(
 		cx-tWidthTop/2,
 		cy-tHeightVert/2,
 		cx+tWidthTop/2,
 		cy-tHeightVert/2+tHeightTop,
 	)
 
-	vertRect := image.Rect(
+	vert = image.Rect(
 		cx-tWidthVert/2,
-		cy-tHeightVert/2,
+		top.Max.Y,
 		cx+tWidthVert/2,
 		cy+tHeightVert/2,
 	)
-
-	t.Fill(vertRect, shapeColor, screen.Src)
-	t.Fill(topRect, shapeColor, screen.Src)
+	return top, vert
 }
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"image"
+	"testing"
+
+	"golang.org/x/mobile/event/size"
+)
+
+func TestLetterbox(t *testing.T) {
+	for _, tc := range []struct {
+		dst  image.Rectangle
+		size image.Point
+		want image.Rectangle
+	}{
+		{image.Rect(0, 0, 800, 600), image.Pt(400, 400), image.Rect(100, 0, 700, 600)},
+		{image.Rect(0, 0, 600, 800), image.Pt(400, 400), image.Rect(0, 100, 600, 700)},
+		{image.Rect(0, 0, 800, 800), image.Pt(800, 400), image.Rect(0, 200, 800, 600)},
+		{image.Rect(0, 0, 300, 300), image.Pt(100, 100), image.Rect(0, 0, 300, 300)},
+		{image.Rect(0, 0, 300, 300), image.Point{}, image.Rect(0, 0, 300, 300)},
+	} {
+		if got := Letterbox(tc.dst, tc.size); got != tc.want {
+			t.Errorf("Letterbox(%v, %v) = %v, want %v", tc.dst, tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestFramePoint(t *testing.T) {
+	dst := image.Rect(100, 0, 500, 200)
+	for _, tc := range []struct {
+		px, py float32
+		x, y   float64
+		inside bool
+	}{
+		{100, 0, 0, 0, true},
+		{300, 50, 0.5, 0.25, true},
+		{500, 100, 1, 0.5, false},
+		{50, 100, -0.125, 0.5, false},
+	} {
+		x, y, inside := framePoint(dst, tc.px, tc.py)
+		if x != tc.x || y != tc.y || inside != tc.inside {
+			t.Errorf("framePoint(%v, %g, %g) = %g, %g, %t, want %g, %g, %t", dst, tc.px, tc.py, x, y, inside, tc.x, tc.y, tc.inside)
+		}
+	}
+}
+
+func TestVisualizer_CanvasSize(t *testing.T) {
+	// HiDPI: 800x600 точок при двох пікселях на точку.
+	pw := Visualizer{sz: size.Event{WidthPx: 1600, HeightPx: 1200, WidthPt: 800, HeightPt: 600, PixelsPerPt: 2}}
+	if got := pw.canvasSize(); got != image.Pt(1600, 1200) {
+		t.Errorf("canvas should fill the window in device pixels, got %v", got)
+	}
+	pw.KeepAspect, pw.Aspect = true, image.Pt(400, 400)
+	if got := pw.canvasSize(); got != image.Pt(1200, 1200) {
+		t.Errorf("letterboxed canvas should have device pixel size, got %v", got)
+	}
+	if got := pw.frameRect(nil); got != image.Rect(200, 0, 1400, 1200) {
+		t.Errorf("unexpected letterbox %v", got)
+	}
+}